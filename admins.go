@@ -0,0 +1,200 @@
+package gocouch
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// adminsSection is a configuration section holding server administrators
+const adminsSection = "admins"
+
+// ErrAdminExists is returned by CreateAdmin when administrator is already defined
+var ErrAdminExists = errors.New("Admin already exists")
+
+// adminConfigs returns configurations of given nodes, when nodes are not
+// specified all cluster nodes are used, or global config on CouchDB 1.x
+func (srv *Server) adminConfigs(nodes []string) ([]*Config, error) {
+	if len(nodes) == 0 {
+		membership, err := srv.Membership()
+		if err != nil && !isStatus(err, 400) && !isStatus(err, 404) {
+			return nil, err
+		}
+		if err != nil || len(membership.ClusterNodes) == 0 {
+			config, err := srv.LocalConfig()
+			if err != nil {
+				return nil, err
+			}
+			return []*Config{config}, nil
+		}
+		nodes = membership.ClusterNodes
+	}
+	configs := make([]*Config, 0, len(nodes))
+	for _, node := range nodes {
+		configs = append(configs, srv.Config(node))
+	}
+	return configs, nil
+}
+
+// CreateAdmin creates server administrator on given nodes, or on every node
+// of the cluster when nodes are omitted. Password is hashed by the client,
+// so every node stores the same hash
+func (srv *Server) CreateAdmin(name, password string, nodes ...string) error {
+	configs, err := srv.adminConfigs(nodes)
+	if err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if _, err := config.Get(adminsSection, name); err == nil {
+			return ErrAdminExists
+		} else if !isStatus(err, 404) {
+			return err
+		}
+	}
+	return srv.setAdmin(configs, name, password)
+}
+
+// adminIterations is a PBKDF2 iterations count used by the server by default
+const adminIterations = 10
+
+// setAdmin stores the same password hash on every node, since every node
+// salts plain password on its own and sessions issued by one node would not
+// be accepted by others. Node serving requests is updated last, so that
+// current credentials (or admin party) stay valid until the other nodes
+// are done
+func (srv *Server) setAdmin(configs []*Config, name, password string) error {
+	hash, err := adminHash(password)
+	if err != nil {
+		return err
+	}
+	if local, err := srv.localNodeName(); err == nil {
+		localPath := srv.Config(local).path
+		sorted := make([]*Config, 0, len(configs))
+		var last []*Config
+		for _, config := range configs {
+			if config.path == localPath {
+				last = append(last, config)
+			} else {
+				sorted = append(sorted, config)
+			}
+		}
+		configs = append(sorted, last...)
+	}
+	for _, config := range configs {
+		if _, err := config.Set(adminsSection, name, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localNodeName returns name of the node serving requests (CouchDB 2.x+)
+func (srv *Server) localNodeName() (string, error) {
+	resp, err := srv.conn.request("GET", queryURL("_node", LocalNode), nil, nil, srv.auth, 0)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Name string `json:"name"`
+	}
+	if err := parseBody(resp, &out); err != nil {
+		return "", err
+	}
+	return out.Name, nil
+}
+
+// adminHash returns password hash in the form stored by the server in the
+// admins section: `-pbkdf2-<derived key>,<salt>,<iterations>`
+func adminHash(password string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	salt := hex.EncodeToString(random)
+	key := pbkdf2SHA1([]byte(password), []byte(salt), adminIterations)
+	return fmt.Sprintf("-pbkdf2-%x,%s,%d", key, salt, adminIterations), nil
+}
+
+// pbkdf2SHA1 derives a single block key of RFC 2898 PBKDF2 with HMAC-SHA1
+func pbkdf2SHA1(password, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha1.New, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// SetAdminPassword changes password of existing administrator
+func (srv *Server) SetAdminPassword(name, password string, nodes ...string) error {
+	configs, err := srv.adminConfigs(nodes)
+	if err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if _, err := config.Get(adminsSection, name); err != nil {
+			return err
+		}
+	}
+	return srv.setAdmin(configs, name, password)
+}
+
+// DeleteAdmin removes server administrator
+func (srv *Server) DeleteAdmin(name string, nodes ...string) error {
+	configs, err := srv.adminConfigs(nodes)
+	if err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if _, err := config.Delete(adminsSection, name); err != nil && !isStatus(err, 404) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAdmins returns sorted names of administrators defined on any of nodes
+func (srv *Server) ListAdmins(nodes ...string) ([]string, error) {
+	configs, err := srv.adminConfigs(nodes)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, config := range configs {
+		admins, err := config.Section(adminsSection)
+		if err != nil && !isStatus(err, 404) {
+			return nil, err
+		}
+		for name := range admins {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// IsAdminParty reports whether server has no administrators, which means
+// that every anonymous request has admin privileges
+func (srv *Server) IsAdminParty() (bool, error) {
+	info, err := sessionInfo(srv.conn, nil)
+	if err != nil {
+		return false, err
+	}
+	return info.IsAdmin(), nil
+}
@@ -0,0 +1,161 @@
+package gocouch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestServer_CreateAdmin(t *testing.T) {
+	srv := getConnection(t)
+	if err := srv.CreateAdmin("gocouch_admin", "secret"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer srv.DeleteAdmin("gocouch_admin")
+	if err := srv.CreateAdmin("gocouch_admin", "secret"); err != ErrAdminExists {
+		t.Logf("Expected ErrAdminExists, got: %v\n", err)
+		t.Fail()
+	}
+	admins, err := srv.ListAdmins()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	found := false
+	for _, name := range admins {
+		found = found || name == "gocouch_admin"
+	}
+	if !found {
+		t.Logf("Admin is not listed: %v\n", admins)
+		t.Fail()
+	}
+	if err := srv.SetAdminPassword("gocouch_admin", "new_secret"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	admin, _ := Connect("localhost", 5984, BasicAuth{"gocouch_admin", "new_secret"}, 0)
+	if _, err := admin.GetAllDBs(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestServer_IsAdminParty(t *testing.T) {
+	srv := getConnection(t)
+	party, err := srv.IsAdminParty()
+	if err != nil || party {
+		t.Logf("Error: %v, admin party: %v\n", err, party)
+		t.Fail()
+	}
+}
+
+// fakeAdminCluster serves admins config of nodes, requests are coordinated
+// by node1 which authenticates them against its own admins
+func fakeAdminCluster(admins map[string]string, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authorized := true
+		for key, hash := range admins {
+			if !strings.HasPrefix(key, "node1/") {
+				continue
+			}
+			authorized = false
+			user, pass, ok := r.BasicAuth()
+			parts := strings.Split(strings.TrimPrefix(hash, "-pbkdf2-"), ",")
+			if ok && key == "node1/"+user && len(parts) == 3 &&
+				parts[0] == hex.EncodeToString(pbkdf2SHA1([]byte(pass), []byte(parts[1]), adminIterations)) {
+				authorized = true
+				break
+			}
+		}
+		if !authorized {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":"unauthorized","reason":"Name or password is incorrect."}`))
+			return
+		}
+		if r.URL.Path == "/_node/_local" {
+			w.Write([]byte(`{"name":"node1"}`))
+			return
+		}
+		// path is /_node/<node>/_config/admins/<name>
+		parts := strings.Split(r.URL.Path, "/")
+		key := parts[2] + "/" + parts[5]
+		switch r.Method {
+		case "GET":
+			if value, ok := admins[key]; ok {
+				json.NewEncoder(w).Encode(value)
+				return
+			}
+			w.WriteHeader(404)
+			w.Write([]byte(`{"error":"not_found","reason":"unknown_config_value"}`))
+		case "PUT":
+			var value string
+			json.NewDecoder(r.Body).Decode(&value)
+			if !strings.HasPrefix(value, "-pbkdf2-") {
+				// every node salts plain password differently
+				value = "-pbkdf2-" + value + "," + parts[2] + ",10"
+			}
+			json.NewEncoder(w).Encode(admins[key])
+			admins[key] = value
+		}
+	}))
+}
+
+func TestServer_CreateAdminCluster(t *testing.T) {
+	var mu sync.Mutex
+	admins := map[string]string{}
+	ts := fakeAdminCluster(admins, &mu)
+	defer ts.Close()
+	nodes := []string{"node1", "node2", "node3"}
+	conn, _ := createConnection(ts.URL, 0)
+	// the first admin ends admin party, then admin rotates own password
+	party := &Server{conn: conn}
+	admin := &Server{conn: conn, auth: BasicAuth{"admin", "secret"}}
+	for _, set := range []func() error{
+		func() error { return party.CreateAdmin("admin", "secret", nodes...) },
+		func() error { return admin.SetAdminPassword("admin", "new_secret", nodes...) },
+	} {
+		if err := set(); err != nil {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+			return
+		}
+		mu.Lock()
+		hash := admins["node1/admin"]
+		for _, node := range nodes {
+			if admins[node+"/admin"] != hash {
+				t.Logf("Admin hash differs across nodes: %v\n", admins)
+				t.Fail()
+			}
+		}
+		mu.Unlock()
+	}
+	rotated := &Server{conn: conn, auth: BasicAuth{"admin", "new_secret"}}
+	if _, err := rotated.Config("node2").Get(adminsSection, "admin"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestPBKDF2SHA1(t *testing.T) {
+	// RFC 6070 test vectors
+	for iterations, expected := range map[int]string{
+		1:    "0c60c80f961f0e71f3a9b524af6012062fe037a6",
+		2:    "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957",
+		4096: "4b007901b765489abead49d926f721d065a429c1",
+	} {
+		if key := hex.EncodeToString(pbkdf2SHA1([]byte("password"), []byte("salt"), iterations)); key != expected {
+			t.Logf("Unexpected key for %d iterations: %s\n", iterations, key)
+			t.Fail()
+		}
+	}
+}
@@ -2,10 +2,15 @@ package gocouch
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"hash"
 	"net/http"
+	"strings"
 )
 
 // Auth is a common interface that provides
@@ -15,6 +20,50 @@ type Auth interface {
 	AddAuthHeaders(*http.Request)
 }
 
+type authContextKey struct{}
+
+// ContextWithAuth returns context carrying Auth of the request, e.g. set by
+// middleware authenticating end user
+func ContextWithAuth(ctx context.Context, auth Auth) context.Context {
+	return context.WithValue(ctx, authContextKey{}, auth)
+}
+
+// AuthFromContext returns Auth stored by ContextWithAuth
+func AuthFromContext(ctx context.Context) (Auth, bool) {
+	auth, ok := ctx.Value(authContextKey{}).(Auth)
+	return auth, ok
+}
+
+// WithAuth returns a view of the server making requests with given Auth,
+// nil means anonymous requests. Connection pool is shared with original
+func (srv *Server) WithAuth(auth Auth) *Server {
+	return &Server{auth: auth, conn: srv.conn}
+}
+
+// ForContext returns a view of the server using Auth stored in context, or
+// the server itself when there is none
+func (srv *Server) ForContext(ctx context.Context) *Server {
+	if auth, ok := AuthFromContext(ctx); ok {
+		return srv.WithAuth(auth)
+	}
+	return srv
+}
+
+// WithAuth returns a view of the database making requests with given Auth,
+// nil means anonymous requests. Connection pool is shared with original
+func (db *Database) WithAuth(auth Auth) *Database {
+	return &Database{conn: db.conn, auth: auth, Name: db.Name}
+}
+
+// ForContext returns a view of the database using Auth stored in context,
+// or the database itself when there is none
+func (db *Database) ForContext(ctx context.Context) *Database {
+	if auth, ok := AuthFromContext(ctx); ok {
+		return db.WithAuth(auth)
+	}
+	return db
+}
+
 // BasicAuth provides simple user:password authentication
 type BasicAuth struct {
 	Username, Password string
@@ -27,6 +76,45 @@ func (ba BasicAuth) AddAuthHeaders(req *http.Request) {
 	req.Header.Add("Authorization", header)
 }
 
+// ProxyAuth authenticates requests on behalf of a user authenticated by a
+// trusted proxy. Token is signed with the secret shared with the server
+// (`chttpd_auth/secret`) and is omitted when Secret is empty. Hash defaults
+// to SHA1, use sha256.New for servers configured with `hash_algorithms`
+type ProxyAuth struct {
+	Username string
+	Roles    []string
+	Secret   string
+	Hash     func() hash.Hash
+}
+
+// ForUser returns a copy of proxy authentication acting as given user
+func (pa ProxyAuth) ForUser(username string, roles ...string) ProxyAuth {
+	pa.Username, pa.Roles = username, roles
+	return pa
+}
+
+// Token returns signature of the user name
+func (pa ProxyAuth) Token() string {
+	h := pa.Hash
+	if h == nil {
+		h = sha1.New
+	}
+	mac := hmac.New(h, []byte(pa.Secret))
+	mac.Write([]byte(pa.Username))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AddAuthHeaders adds proxy authentication headers to the request
+func (pa ProxyAuth) AddAuthHeaders(req *http.Request) {
+	req.Header.Set("X-Auth-CouchDB-UserName", pa.Username)
+	if len(pa.Roles) > 0 {
+		req.Header.Set("X-Auth-CouchDB-Roles", strings.Join(pa.Roles, ","))
+	}
+	if pa.Secret != "" {
+		req.Header.Set("X-Auth-CouchDB-Token", pa.Token())
+	}
+}
+
 // Session stores authentication cookie for current user at the CouchDB instance
 type Session struct {
 	cookie *http.Cookie
@@ -35,25 +123,9 @@ type Session struct {
 
 // AddAuthHeaders add cookie to request
 func (s Session) AddAuthHeaders(req *http.Request) {
-	req.AddCookie(s.cookie)
-}
-
-// UserRecord is userd to create new user in couchdb instance
-type UserRecord struct {
-	Login    string   `json:"name"`
-	Type     string   `json:"type"`
-	Roles    []string `json:"roles"`
-	Password string   `json:"password"`
-}
-
-// CreateUser inserts user record to couchdb _users database
-func (srv *Server) CreateUser(user *UserRecord) error {
-	db, err := srv.MustGetDatabase("_users", srv.auth)
-	if err != nil {
-		return err
+	if s.cookie != nil {
+		req.AddCookie(s.cookie)
 	}
-	_, err = db.Put(fmt.Sprintf("org.couchdb.user:%s", user.Login), user)
-	return err
 }
 
 // NewSession authenticates user and returns Session struct containing current
@@ -69,26 +141,73 @@ func (srv *Server) NewSession(user, pass string) (*Session, error) {
 	if err != nil {
 		return nil, err
 	}
-	s := Session{srv: srv}
-	for _, cookie := range resp.Cookies() {
-		if cookie.Name == "AuthSession" {
-			s.cookie = cookie
-		}
+	s := Session{srv: srv, cookie: responseCookie(resp)}
+	if err := resp.Body.Close(); err != nil {
+		return nil, err
+	}
+	if s.cookie == nil {
+		return nil, ErrNoSessionCookie
 	}
 	return &s, nil
 }
 
-// Info returns information about current session info
-func (s *Session) Info() (map[string]interface{}, error) {
-	resp, err := s.srv.conn.request("GET", "/_session", nil, nil, s, 0)
+// SessionInfo describes user authenticated by the request
+type SessionInfo struct {
+	OK      bool        `json:"ok"`
+	UserCtx UserContext `json:"userCtx"`
+	Info    struct {
+		AuthenticationDB       string   `json:"authentication_db"`
+		AuthenticationHandlers []string `json:"authentication_handlers"`
+		Authenticated          string   `json:"authenticated"`
+	} `json:"info"`
+}
+
+// IsAdmin reports whether user is a server admin
+func (i *SessionInfo) IsAdmin() bool {
+	return i.UserCtx.IsAdmin()
+}
+
+// HasRole reports whether user has given role
+func (i *SessionInfo) HasRole(role string) bool {
+	return i.UserCtx.HasRole(role)
+}
+
+// IsAdmin reports whether user has `_admin` role
+func (c *UserContext) IsAdmin() bool {
+	return c.HasRole("_admin")
+}
+
+// HasRole reports whether user has given role
+func (c *UserContext) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func sessionInfo(conn *connection, auth Auth) (*SessionInfo, error) {
+	resp, err := conn.request("GET", "/_session", nil, nil, auth, 0)
 	if err != nil {
 		return nil, err
 	}
-	var result map[string]interface{}
+	var result SessionInfo
 	if err := parseBody(resp, &result); err != nil {
 		return nil, err
 	}
-	return result, nil
+	return &result, nil
+}
+
+// Info returns information about current session info
+func (s *Session) Info() (*SessionInfo, error) {
+	return sessionInfo(s.srv.conn, s)
+}
+
+// SessionInfo returns information about user authenticated by server Auth,
+// user name is empty for anonymous requests
+func (srv *Server) SessionInfo() (*SessionInfo, error) {
+	return sessionInfo(srv.conn, srv.auth)
 }
 
 // Close deletes current session
@@ -1,6 +1,12 @@
 package gocouch
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
 	"testing"
 )
 
@@ -95,7 +101,7 @@ func TestSession_Info(t *testing.T) {
 		t.Fail()
 		return
 	}
-	if res["userCtx"].(map[string]interface{})["name"].(string) != "milk" {
+	if res.UserCtx.Name != "milk" {
 		t.Log("Incorrect user")
 		t.Fail()
 	}
@@ -114,3 +120,100 @@ func TestSession_Close(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestSessionInfo_HasRole(t *testing.T) {
+	var info SessionInfo
+	data := `{"ok":true,"userCtx":{"name":"milk","roles":["_admin","reader"]},
+		"info":{"authentication_db":"_users","authentication_handlers":["cookie","default"],"authenticated":"default"}}`
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if !info.IsAdmin() || !info.HasRole("reader") || info.HasRole("editor") {
+		t.Logf("Unexpected roles: %v\n", info.UserCtx.Roles)
+		t.Fail()
+	}
+	if info.Info.Authenticated != "default" || len(info.Info.AuthenticationHandlers) != 2 {
+		t.Logf("Unexpected info: %+v\n", info.Info)
+		t.Fail()
+	}
+}
+
+func TestServer_SessionInfo(t *testing.T) {
+	srv := getConnection(t)
+	info, err := srv.SessionInfo()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if !info.OK || !info.IsAdmin() {
+		t.Logf("Unexpected session: %+v\n", info)
+		t.Fail()
+	}
+}
+
+func TestProxyAuth_AddAuthHeaders(t *testing.T) {
+	gateway := ProxyAuth{Secret: "92de07df7e7a3fe14808cef90a7cc0d9", Hash: sha256.New}
+	req, _ := http.NewRequest("GET", "http://localhost:5984/_session", nil)
+	gateway.ForUser("milk", "reader", "editor").AddAuthHeaders(req)
+	mac := hmac.New(sha256.New, []byte(gateway.Secret))
+	mac.Write([]byte("milk"))
+	if req.Header.Get("X-Auth-CouchDB-Token") != hex.EncodeToString(mac.Sum(nil)) {
+		t.Logf("Unexpected token: %v\n", req.Header.Get("X-Auth-CouchDB-Token"))
+		t.Fail()
+	}
+	if req.Header.Get("X-Auth-CouchDB-UserName") != "milk" || req.Header.Get("X-Auth-CouchDB-Roles") != "reader,editor" {
+		t.Logf("Unexpected headers: %v\n", req.Header)
+		t.Fail()
+	}
+	if gateway.Username != "" {
+		t.Log("ForUser must not modify original")
+		t.Fail()
+	}
+}
+
+func TestDatabase_ForContext(t *testing.T) {
+	srv, _ := Connect("localhost", 5984, BasicAuth{"admin", "admin"}, 0)
+	db := &Database{conn: srv.conn, auth: srv.auth, Name: "db"}
+	if db.ForContext(context.Background()) != db {
+		t.Log("Database without context auth must be returned as is")
+		t.Fail()
+	}
+	user := BasicAuth{"milk", "220162"}
+	view := db.ForContext(ContextWithAuth(context.Background(), user))
+	if view.auth != user || view.conn != db.conn || db.auth != srv.auth {
+		t.Logf("Unexpected view: %+v\n", view)
+		t.Fail()
+	}
+	if anonymous := srv.WithAuth(nil); anonymous.auth != nil || anonymous.conn != srv.conn {
+		t.Logf("Unexpected view: %+v\n", anonymous)
+		t.Fail()
+	}
+}
+
+func TestDatabase_WithAuth(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("with_auth_test", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	sec := db.GetDatabaseSecurity()
+	if err := sec.AddMember("milk"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if _, err := db.WithAuth(nil).Info(); !isStatus(err, 401) {
+		t.Logf("Expected 401 for anonymous user, got: %v\n", err)
+		t.Fail()
+	}
+	if _, err := db.WithAuth(BasicAuth{"milk", "220162"}).Info(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+}
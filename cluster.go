@@ -0,0 +1,246 @@
+package gocouch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// LocalNode refers to the node serving the request in node level endpoints
+const LocalNode = "_local"
+
+// Cluster setup actions
+const (
+	ClusterEnable     = "enable_cluster"
+	ClusterAddNode    = "add_node"
+	ClusterFinish     = "finish_cluster"
+	ClusterEnableNode = "enable_single_node"
+)
+
+// Membership describes nodes known to the node and ones forming the cluster
+type Membership struct {
+	AllNodes     []string `json:"all_nodes"`
+	ClusterNodes []string `json:"cluster_nodes"`
+}
+
+// ClusterSetupRequest is a body of `_cluster_setup` request, only fields
+// related to the Action are used by the server
+type ClusterSetupRequest struct {
+	Action                string   `json:"action"`
+	Username              string   `json:"username,omitempty"`
+	Password              string   `json:"password,omitempty"`
+	BindAddress           string   `json:"bind_address,omitempty"`
+	Port                  int      `json:"port,omitempty"`
+	NodeCount             int      `json:"node_count,omitempty"`
+	Host                  string   `json:"host,omitempty"`
+	RemoteNode            string   `json:"remote_node,omitempty"`
+	RemoteCurrentUser     string   `json:"remote_current_user,omitempty"`
+	RemoteCurrentPassword string   `json:"remote_current_password,omitempty"`
+	EnsureDBsExist        []string `json:"ensure_dbs_exist,omitempty"`
+}
+
+// UpStatus is a health status of the node, Status is "ok" for healthy node
+// and "maintenance_mode" or "nolb" otherwise
+type UpStatus struct {
+	Status string                 `json:"status"`
+	Seeds  map[string]interface{} `json:"seeds"`
+}
+
+// NodeSystem contains Erlang VM statistics of a node
+type NodeSystem struct {
+	Uptime                  int64                      `json:"uptime"`
+	Memory                  map[string]int64           `json:"memory"`
+	RunQueue                int                        `json:"run_queue"`
+	EtsTableCount           int                        `json:"ets_table_count"`
+	ContextSwitches         int64                      `json:"context_switches"`
+	Reductions              int64                      `json:"reductions"`
+	GarbageCollectionCount  int64                      `json:"garbage_collection_count"`
+	WordsReclaimed          int64                      `json:"words_reclaimed"`
+	IOInput                 int64                      `json:"io_input"`
+	IOOutput                int64                      `json:"io_output"`
+	OSProcCount             int                        `json:"os_proc_count"`
+	StaleProcCount          int                        `json:"stale_proc_count"`
+	ProcessCount            int                        `json:"process_count"`
+	ProcessLimit            int                        `json:"process_limit"`
+	InternalReplicationJobs int                        `json:"internal_replication_jobs"`
+	MessageQueues           map[string]json.RawMessage `json:"message_queues"`
+}
+
+// NodeVersions describes versions of node components
+type NodeVersions struct {
+	JavascriptEngine struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"javascript_engine"`
+	Erlang struct {
+		Version         string   `json:"version"`
+		SupportedHashes []string `json:"supported_hashes"`
+	} `json:"erlang"`
+	CollatorVersions []string `json:"collator_versions"`
+}
+
+// Membership returns nodes of the cluster, CouchDB 1.x answers with an error
+func (srv *Server) Membership() (*Membership, error) {
+	resp, err := srv.conn.request("GET", "/_membership", nil, nil, srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out Membership
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ClusterSetup performs a step of cluster setup
+func (srv *Server) ClusterSetup(req *ClusterSetupRequest) error {
+	headers := map[string]string{"Content-Type": appJSON}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := srv.conn.request("POST", "/_cluster_setup", headers, bytes.NewReader(payload), srv.auth, 0)
+	if err != nil {
+		return err
+	}
+	var result map[string]interface{}
+	return parseBody(resp, &result)
+}
+
+// EnableCluster configures the node to be a part of a cluster of nodeCount
+// nodes with given admin credentials
+func (srv *Server) EnableCluster(username, password, bindAddress string, nodeCount int) error {
+	return srv.ClusterSetup(&ClusterSetupRequest{
+		Action:      ClusterEnable,
+		Username:    username,
+		Password:    password,
+		BindAddress: bindAddress,
+		NodeCount:   nodeCount,
+	})
+}
+
+// EnableRemoteNode configures remote node to join the cluster, it's done
+// through the coordinating node using remote node's current credentials
+func (srv *Server) EnableRemoteNode(req *ClusterSetupRequest) error {
+	remote := *req
+	remote.Action = ClusterEnable
+	return srv.ClusterSetup(&remote)
+}
+
+// AddNode adds configured node to the cluster
+func (srv *Server) AddNode(host string, port int, username, password string) error {
+	return srv.ClusterSetup(&ClusterSetupRequest{
+		Action:   ClusterAddNode,
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+	})
+}
+
+// FinishCluster completes cluster setup and creates system databases
+func (srv *Server) FinishCluster() error {
+	return srv.ClusterSetup(&ClusterSetupRequest{Action: ClusterFinish})
+}
+
+// ClusterSetupStatus returns state of cluster setup, e.g. "cluster_disabled",
+// "cluster_enabled", "cluster_finished" or "single_node_enabled"
+func (srv *Server) ClusterSetupStatus(ensureDBsExist ...string) (string, error) {
+	options := Options{}
+	if len(ensureDBsExist) > 0 {
+		dbs, err := json.Marshal(ensureDBsExist)
+		if err != nil {
+			return "", err
+		}
+		options["ensure_dbs_exist"] = string(dbs)
+	}
+	resp, err := srv.conn.request("GET", "/_cluster_setup"+encodeOptions(options), nil, nil, srv.auth, 0)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		State string `json:"state"`
+	}
+	if err := parseBody(resp, &result); err != nil {
+		return "", err
+	}
+	return result.State, nil
+}
+
+// Up checks health of the node. Node in maintenance mode answers with 503,
+// which is not considered an error, check Status instead
+func (srv *Server) Up() (*UpStatus, error) {
+	req, err := srv.conn.newRequest(context.Background(), "GET", "/_up", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := srv.conn.send(req, srv.auth)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, parseError(resp)
+	}
+	var out UpStatus
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NodeSystem returns VM statistics of the node, use LocalNode for current one
+func (srv *Server) NodeSystem(node string) (*NodeSystem, error) {
+	resp, err := srv.conn.request("GET", queryURL("_node", node, "_system"), nil, nil, srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out NodeSystem
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NodeStats provides usage statistics of the node, path selects a subtree
+// like Stats does for CouchDB 1.x
+func (srv *Server) NodeStats(node string, path []string, o interface{}) error {
+	resp, err := srv.conn.request("GET", queryURL(append([]string{"_node", node, "_stats"}, path...)...),
+		nil, nil, srv.auth, 0)
+	if err != nil {
+		return err
+	}
+	return parseBody(resp, o)
+}
+
+// NodeVersions returns versions of node components (CouchDB 3.2+)
+func (srv *Server) NodeVersions(node string) (*NodeVersions, error) {
+	resp, err := srv.conn.request("GET", queryURL("_node", node, "_versions"), nil, nil, srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out NodeVersions
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestartNode restarts the node, admin privileges are required
+func (srv *Server) RestartNode(node string) error {
+	headers := map[string]string{"Content-Type": appJSON}
+	resp, err := srv.conn.request("POST", queryURL("_node", node, "_restart"), headers, nil, srv.auth, 0)
+	if err != nil {
+		return err
+	}
+	var result map[string]interface{}
+	return parseBody(resp, &result)
+}
+
+// SetMaintenanceMode turns maintenance mode of the node on or off, node in
+// maintenance mode is excluded from serving requests by the cluster
+func (srv *Server) SetMaintenanceMode(node string, enabled bool) error {
+	_, err := srv.Config(node).Set("couchdb", "maintenance_mode", strconv.FormatBool(enabled))
+	return err
+}
@@ -0,0 +1,124 @@
+package gocouch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// cluster endpoints are only supported by couchdb 2.0+
+func clusterSupported(t *testing.T, err error) bool {
+	if isStatus(err, 400) || isStatus(err, 404) {
+		t.Skip("Not supported by server")
+		return false
+	}
+	return true
+}
+
+func TestServer_Membership(t *testing.T) {
+	srv := getConnection(t)
+	membership, err := srv.Membership()
+	if err != nil {
+		if clusterSupported(t, err) {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+		}
+		return
+	}
+	if len(membership.AllNodes) < 1 {
+		t.Logf("Unexpected membership: %#v\n", membership)
+		t.Fail()
+	}
+}
+
+func TestServer_Up(t *testing.T) {
+	srv := getConnection(t)
+	status, err := srv.Up()
+	if err != nil {
+		if clusterSupported(t, err) {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+		}
+		return
+	}
+	if status.Status != "ok" {
+		t.Logf("Unexpected status: %#v\n", status)
+		t.Fail()
+	}
+}
+
+func TestServer_NodeSystem(t *testing.T) {
+	srv := getConnection(t)
+	system, err := srv.NodeSystem(LocalNode)
+	if err != nil {
+		if clusterSupported(t, err) {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+		}
+		return
+	}
+	if system.Uptime <= 0 || system.ProcessCount <= 0 {
+		t.Logf("Unexpected system info: %#v\n", system)
+		t.Fail()
+	}
+	var stats map[string]interface{}
+	if err := srv.NodeStats(LocalNode, []string{"couchdb", "request_time"}, &stats); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestServer_ClusterSetupStatus(t *testing.T) {
+	srv := getConnection(t)
+	state, err := srv.ClusterSetupStatus()
+	if err != nil {
+		if clusterSupported(t, err) {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+		}
+		return
+	}
+	if state == "" {
+		t.Log("Empty cluster setup state")
+		t.Fail()
+	}
+}
+
+func TestServer_SetMaintenanceMode(t *testing.T) {
+	srv := getConnection(t)
+	if err := srv.SetMaintenanceMode(LocalNode, true); err != nil {
+		if clusterSupported(t, err) {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+		}
+		return
+	}
+	defer srv.SetMaintenanceMode(LocalNode, false)
+	status, err := srv.Up()
+	if err != nil || status.Status != "maintenance_mode" {
+		t.Logf("Error: %v, status: %#v\n", err, status)
+		t.Fail()
+	}
+}
+
+func TestServer_UpRenewingAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":"unauthorized","reason":"no token"}`))
+			return
+		}
+		w.WriteHeader(503)
+		w.Write([]byte(`{"status":"maintenance_mode"}`))
+	}))
+	defer ts.Close()
+	conn, _ := createConnection(ts.URL, 0)
+	srv := &Server{conn: conn, auth: NewJWTAuth(TokenSourceFunc(func() (*Token, error) {
+		return &Token{Value: "token"}, nil
+	}))}
+	status, err := srv.Up()
+	if err != nil || status.Status != "maintenance_mode" {
+		t.Logf("Error: %v, status: %#v\n", err, status)
+		t.Fail()
+	}
+}
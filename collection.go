@@ -0,0 +1,267 @@
+package gocouch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Collection is a typed access to documents of the database. T is a struct
+// with string fields tagged `json:"_id,omitempty"` and `json:"_rev,omitempty"`
+// (they may be in embedded struct), which are updated after every write
+type Collection[T any] struct {
+	db *Database
+}
+
+// TypedRow is a row of view result with document decoded to T, Doc is nil
+// for missing and deleted documents
+type TypedRow[T any] struct {
+	ID    string          `json:"id"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+	Doc   *T              `json:"doc"`
+	Error string          `json:"error,omitempty"`
+}
+
+// TypedViewResult is a view result with documents decoded to T
+type TypedViewResult[T any] struct {
+	Offset    int           `json:"offset"`
+	TotalRows int           `json:"total_rows"`
+	Rows      []TypedRow[T] `json:"rows"`
+}
+
+// FindQuery is a Mango query
+type FindQuery struct {
+	Selector interface{}   `json:"selector"`
+	Fields   []string      `json:"fields,omitempty"`
+	Sort     []interface{} `json:"sort,omitempty"`
+	Limit    int           `json:"limit,omitempty"`
+	Skip     int           `json:"skip,omitempty"`
+	UseIndex interface{}   `json:"use_index,omitempty"`
+	Bookmark string        `json:"bookmark,omitempty"`
+}
+
+// FindResult contains documents matched by Mango query, Bookmark allows
+// to request the next page
+type FindResult[T any] struct {
+	Docs     []T    `json:"docs"`
+	Bookmark string `json:"bookmark"`
+	Warning  string `json:"warning"`
+}
+
+// ErrNoDocumentMeta is returned by writes of collection whose document type
+// has no `_id` and `_rev` fields
+var ErrNoDocumentMeta = errors.New("Document type has no _id and _rev fields")
+
+// NewCollection returns collection of documents of type T stored in db
+func NewCollection[T any](db *Database) *Collection[T] {
+	return &Collection[T]{db: db}
+}
+
+// Database returns database of the collection
+func (c *Collection[T]) Database() *Database {
+	return c.db
+}
+
+// Docs returns documents present in result
+func (r *TypedViewResult[T]) Docs() []T {
+	docs := make([]T, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		if row.Doc != nil {
+			docs = append(docs, *row.Doc)
+		}
+	}
+	return docs
+}
+
+// Get fetches document by id
+func (c *Collection[T]) Get(id string) (T, error) {
+	var doc T
+	err := c.db.Get(docPath(id), &doc, nil)
+	return doc, err
+}
+
+// checkMeta ensures on a zero document that T has `_id` and `_rev`, so
+// documents can be updated after writes
+func (c *Collection[T]) checkMeta() error {
+	if !setDocumentMeta(new(T), "", "") {
+		return ErrNoDocumentMeta
+	}
+	return nil
+}
+
+// Put saves document, it's created when id is empty and updated otherwise
+func (c *Collection[T]) Put(doc *T) error {
+	if err := c.checkMeta(); err != nil {
+		return err
+	}
+	id, _ := documentMeta(doc)
+	if id == "" {
+		id, rev, err := c.db.Insert(doc, false, false)
+		if err != nil {
+			return err
+		}
+		setDocumentMeta(doc, id, rev)
+		return nil
+	}
+	rev, err := c.db.Put(docPath(id), doc)
+	if err != nil {
+		return err
+	}
+	setDocumentMeta(doc, id, rev)
+	return nil
+}
+
+// PutMany saves documents in a single request, revisions of saved ones are
+// updated, check results for failed documents
+func (c *Collection[T]) PutMany(docs []*T) ([]UpdateResult, error) {
+	if err := c.checkMeta(); err != nil {
+		return nil, err
+	}
+	results, err := c.db.InsertMany(docs)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if i < len(docs) && result.Error == "" && result.Rev != "" {
+			setDocumentMeta(docs[i], result.ID, result.Rev)
+		}
+	}
+	return results, nil
+}
+
+// Delete deletes document, its revision is set to the deletion one
+func (c *Collection[T]) Delete(doc *T) error {
+	if err := c.checkMeta(); err != nil {
+		return err
+	}
+	id, rev := documentMeta(doc)
+	rev, err := c.db.Del(docPath(id), rev)
+	if err != nil {
+		return err
+	}
+	setDocumentMeta(doc, id, rev)
+	return nil
+}
+
+// UpdateFunc applies fn to the latest revision of the document and saves
+// it retrying on conflicts, see Database.UpdateFunc
+func (c *Collection[T]) UpdateFunc(id string, fn func(doc *T, exists bool) error, options *UpdateOptions) (T, error) {
+	doc, _, err := c.db.updateLoop(id, func() interface{} {
+		return new(T)
+	}, func(doc interface{}, exists bool) error {
+		return fn(doc.(*T), exists)
+	}, options)
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+	return *doc.(*T), nil
+}
+
+// GetMany fetches documents by ids in a single request, missing and deleted
+// documents are skipped
+func (c *Collection[T]) GetMany(ids []string) ([]T, error) {
+	headers := map[string]string{"Content-Type": appJSON}
+	payload, err := json.Marshal(map[string][]string{"keys": ids})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.db.conn.request("POST", queryURL(c.db.Name, "_all_docs")+encodeOptions(Options{"include_docs": true}),
+		headers, bytes.NewReader(payload), c.db.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var result TypedViewResult[T]
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	return result.Docs(), nil
+}
+
+// AllDocs returns documents of the database except design ones. When limit
+// is set rows following skipped design documents are fetched, so the page
+// is full unless the end of the database is reached
+func (c *Collection[T]) AllDocs(options Options) (*TypedViewResult[T], error) {
+	limit, err := strconv.Atoi(fmt.Sprint(options["limit"]))
+	if err != nil {
+		limit = 0
+	}
+	query := Options{}
+	for k, v := range options {
+		query[k] = v
+	}
+	var out *TypedViewResult[T]
+	for {
+		result, err := c.query(queryURL(c.db.Name, "_all_docs"), query)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = &TypedViewResult[T]{Offset: result.Offset, TotalRows: result.TotalRows}
+		}
+		for _, row := range result.Rows {
+			if !strings.HasPrefix(row.ID, "_design/") && (limit <= 0 || len(out.Rows) < limit) {
+				out.Rows = append(out.Rows, row)
+			}
+		}
+		requested, _ := strconv.Atoi(fmt.Sprint(query["limit"]))
+		if limit <= 0 || len(out.Rows) >= limit || len(result.Rows) < requested {
+			return out, nil
+		}
+		// continue after the last row, which is skipped
+		startKey, err := json.Marshal(result.Rows[len(result.Rows)-1].ID)
+		if err != nil {
+			return nil, err
+		}
+		delete(query, "start_key")
+		delete(query, "startkey_docid")
+		delete(query, "start_key_doc_id")
+		query["startkey"] = string(startKey)
+		query["skip"] = 1
+		query["limit"] = limit - len(out.Rows)
+	}
+}
+
+// View queries the view including documents, so `reduce=false` must be
+// set for views having reduce function
+func (c *Collection[T]) View(ddoc, view string, options Options) (*TypedViewResult[T], error) {
+	return c.query(queryURL(c.db.Name, "_design", designName(ddoc), "_view", view), options)
+}
+
+func (c *Collection[T]) query(path string, options Options) (*TypedViewResult[T], error) {
+	query := Options{"include_docs": true}
+	for k, v := range options {
+		query[k] = v
+	}
+	resp, err := c.db.conn.request("GET", path+encodeOptions(query), nil, nil, c.db.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var result TypedViewResult[T]
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Find returns documents matching Mango query (CouchDB 2.x+)
+func (c *Collection[T]) Find(query *FindQuery) (*FindResult[T], error) {
+	headers := map[string]string{"Content-Type": appJSON}
+	payload, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.db.conn.request("POST", queryURL(c.db.Name, "_find"), headers, bytes.NewReader(payload), c.db.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var result FindResult[T]
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,130 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type collectionDoc struct {
+	ID    string `json:"_id,omitempty"`
+	Rev   string `json:"_rev,omitempty"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestCollection(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("collection_test", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	docs := NewCollection[collectionDoc](db)
+	doc := collectionDoc{Name: "first"}
+	if err := docs.Put(&doc); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if doc.ID == "" || doc.Rev == "" {
+		t.Logf("Meta is not updated: %+v\n", doc)
+		t.Fail()
+		return
+	}
+	doc.Count++
+	if err := docs.Put(&doc); err != nil || doc.Rev[:2] != "2-" {
+		t.Logf("Error: %v, doc: %+v\n", err, doc)
+		t.Fail()
+		return
+	}
+	second := &collectionDoc{ID: "second", Name: "second"}
+	if _, err := docs.PutMany([]*collectionDoc{second}); err != nil || second.Rev == "" {
+		t.Logf("Error: %v, doc: %+v\n", err, second)
+		t.Fail()
+		return
+	}
+	stored, err := docs.Get(doc.ID)
+	if err != nil || stored != doc {
+		t.Logf("Error: %v, doc: %+v\n", err, stored)
+		t.Fail()
+	}
+	many, err := docs.GetMany([]string{doc.ID, "second", "missing"})
+	if err != nil || len(many) != 2 {
+		t.Logf("Error: %v, docs: %+v\n", err, many)
+		t.Fail()
+	}
+	all, err := docs.AllDocs(nil)
+	if err != nil || len(all.Docs()) != 2 {
+		t.Logf("Error: %v, result: %+v\n", err, all)
+		t.Fail()
+	}
+	found, err := docs.Find(&FindQuery{Selector: map[string]interface{}{"count": 1}})
+	if err != nil || len(found.Docs) != 1 || found.Docs[0].ID != doc.ID {
+		t.Logf("Error: %v, result: %+v\n", err, found)
+		t.Fail()
+	}
+	updated, err := docs.UpdateFunc("second", func(doc *collectionDoc, exists bool) error {
+		doc.Count = 10
+		return nil
+	}, nil)
+	if err != nil || updated.Count != 10 || updated.Rev[:2] != "2-" {
+		t.Logf("Error: %v, doc: %+v\n", err, updated)
+		t.Fail()
+	}
+	if err := docs.Delete(&doc); err != nil || doc.Rev[:2] != "3-" {
+		t.Logf("Error: %v, doc: %+v\n", err, doc)
+		t.Fail()
+	}
+}
+
+func TestCollection_AllDocsSkipsDesign(t *testing.T) {
+	ids := []string{"_design/a", "_design/b", "_design/c", "x", "y", "z"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		rows := ids
+		if start := query.Get("startkey"); start != "" {
+			var key string
+			json.Unmarshal([]byte(start), &key)
+			for i, id := range ids {
+				if id >= key {
+					rows = ids[i:]
+					break
+				}
+			}
+		}
+		skip, _ := strconv.Atoi(query.Get("skip"))
+		rows = rows[skip:]
+		if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit < len(rows) {
+			rows = rows[:limit]
+		}
+		var result TypedViewResult[collectionDoc]
+		for _, id := range rows {
+			result.Rows = append(result.Rows, TypedRow[collectionDoc]{ID: id, Doc: &collectionDoc{ID: id}})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer ts.Close()
+	conn, _ := createConnection(ts.URL, 0)
+	docs := NewCollection[collectionDoc](&Database{conn: conn, Name: "db"})
+	result, err := docs.AllDocs(Options{"limit": 2})
+	if err != nil || len(result.Rows) != 2 || result.Rows[0].ID != "x" || result.Rows[1].ID != "y" {
+		t.Logf("Error: %v, result: %+v\n", err, result)
+		t.Fail()
+	}
+}
+
+func TestCollection_NoMeta(t *testing.T) {
+	type noMeta struct {
+		Name string `json:"name"`
+	}
+	docs := NewCollection[noMeta](&Database{})
+	if err := docs.Put(&noMeta{}); err != ErrNoDocumentMeta {
+		t.Logf("Expected ErrNoDocumentMeta, got: %v\n", err)
+		t.Fail()
+	}
+}
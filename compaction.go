@@ -0,0 +1,190 @@
+package gocouch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CompactionManager decides when databases and view indexes need compaction
+// based on their fragmentation, runs compactions limiting how many of them
+// are active on the server at once and waits for each of them to finish
+type CompactionManager struct {
+	srv *Server
+	// DatabaseFragmentation is a share of unused database file space (0..1)
+	// above which database is compacted
+	DatabaseFragmentation float64
+	// ViewFragmentation is the same threshold for view indexes
+	ViewFragmentation float64
+	// MinFileSize excludes small files from compaction
+	MinFileSize int64
+	// PollInterval is a period of checking whether compaction finished
+	PollInterval time.Duration
+	slots        chan struct{}
+}
+
+// CompactionResult describes a single compaction check, DesignDocument is
+// empty for the database itself
+type CompactionResult struct {
+	Database       string
+	DesignDocument string
+	Fragmentation  float64
+	FileSize       int64
+	Compacted      bool
+	Err            error
+}
+
+// NewCompactionManager returns manager allowing given count of concurrent
+// compactions with default thresholds of 30% fragmentation and 1MB files
+func (srv *Server) NewCompactionManager(concurrency int) *CompactionManager {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &CompactionManager{
+		srv:                   srv,
+		DatabaseFragmentation: 0.3,
+		ViewFragmentation:     0.3,
+		MinFileSize:           1 << 20,
+		PollInterval:          time.Second,
+		slots:                 make(chan struct{}, concurrency),
+	}
+}
+
+// Run checks all databases of the server and compacts fragmented ones using
+// as many workers as concurrent compactions allowed. Results are returned for
+// every checked database and view index
+func (m *CompactionManager) Run(ctx context.Context) ([]CompactionResult, error) {
+	names, err := m.srv.GetAllDBs()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []CompactionResult
+	)
+	queue := make(chan string)
+	for i := 0; i < cap(m.slots); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				db := &Database{conn: m.srv.conn, auth: m.srv.auth, Name: name}
+				res := m.CompactDatabase(ctx, db)
+				mu.Lock()
+				results = append(results, res...)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range names {
+		select {
+		case queue <- name:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return results, ctx.Err()
+}
+
+// CompactDatabase checks database and its view indexes, compacts the
+// fragmented ones and removes stale index files when any view was compacted
+func (m *CompactionManager) CompactDatabase(ctx context.Context, db *Database) []CompactionResult {
+	info, err := db.Info()
+	if err != nil {
+		return []CompactionResult{{Database: db.Name, Err: err}}
+	}
+	result := CompactionResult{Database: db.Name, Fragmentation: info.Fragmentation(), FileSize: info.FileSize()}
+	if result.FileSize >= m.MinFileSize && result.Fragmentation > m.DatabaseFragmentation {
+		result.Err = m.compact(ctx, db, "")
+		result.Compacted = result.Err == nil
+	}
+	results := []CompactionResult{result}
+	ddocs, err := db.DesignDocs()
+	if err != nil {
+		return append(results, CompactionResult{Database: db.Name, Err: err})
+	}
+	cleanup := false
+	for _, ddoc := range ddocs {
+		info, err := db.DesignInfo(ddoc)
+		if err != nil {
+			results = append(results, CompactionResult{Database: db.Name, DesignDocument: ddoc, Err: err})
+			continue
+		}
+		result := CompactionResult{
+			Database:       db.Name,
+			DesignDocument: ddoc,
+			Fragmentation:  info.ViewIndex.Fragmentation(),
+			FileSize:       info.ViewIndex.FileSize(),
+		}
+		if result.FileSize >= m.MinFileSize && result.Fragmentation > m.ViewFragmentation {
+			result.Err = m.compact(ctx, db, ddoc)
+			result.Compacted = result.Err == nil
+			cleanup = cleanup || result.Compacted
+		}
+		results = append(results, result)
+	}
+	if cleanup {
+		if err := db.ViewCleanup(); err != nil {
+			results = append(results, CompactionResult{Database: db.Name, Err: err})
+		}
+	}
+	return results
+}
+
+// compact waits for a free slot, starts compaction of database or design
+// document and blocks until it's finished
+func (m *CompactionManager) compact(ctx context.Context, db *Database, ddoc string) error {
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-m.slots }()
+	filter := TaskFilter{Type: TaskDatabaseCompaction, Database: db.Name}
+	var err error
+	if ddoc == "" {
+		err = db.Compact()
+	} else {
+		filter = TaskFilter{Type: TaskViewCompaction, Database: db.Name, DesignDocument: ddoc}
+		err = db.CompactDesign(ddoc)
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		if err := m.srv.WaitForTasks(ctx, filter, m.PollInterval); err != nil {
+			return err
+		}
+		// task may not be listed yet or already gone, so double check the
+		// state reported by the database itself
+		running, err := m.compactRunning(db, ddoc)
+		if err != nil || !running {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.PollInterval):
+		}
+	}
+}
+
+func (m *CompactionManager) compactRunning(db *Database, ddoc string) (bool, error) {
+	if ddoc == "" {
+		info, err := db.Info()
+		if err != nil {
+			return false, err
+		}
+		return info.CompactRunning, nil
+	}
+	info, err := db.DesignInfo(ddoc)
+	if err != nil {
+		return false, err
+	}
+	return info.ViewIndex.CompactRunning, nil
+}
@@ -0,0 +1,39 @@
+package gocouch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCompactionManager_CompactDatabase(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("compaction_manager", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	rev, _ := db.Put("doc", map[string]string{"field": "value"})
+	for i := 0; i < 10; i++ {
+		rev, _ = db.Put("doc", map[string]string{"field": "value", "_rev": rev})
+	}
+	manager := srv.NewCompactionManager(1)
+	manager.MinFileSize = 0
+	manager.DatabaseFragmentation = 0
+	manager.PollInterval = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	results := manager.CompactDatabase(ctx, db)
+	if len(results) != 1 || results[0].Err != nil || !results[0].Compacted {
+		t.Logf("Unexpected results: %#v\n", results)
+		t.Fail()
+		return
+	}
+	info, err := db.Info()
+	if err != nil || info.CompactRunning {
+		t.Logf("Error: %v, info: %#v\n", err, info)
+		t.Fail()
+	}
+}
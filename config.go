@@ -0,0 +1,180 @@
+package gocouch
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config provides access to server configuration, it's stored per node in
+// CouchDB 2.x+ and globally in 1.x
+type Config struct {
+	srv  *Server
+	path string
+}
+
+// Config returns configuration of given node, empty node name refers to
+// `/_config` of CouchDB 1.x
+func (srv *Server) Config(node string) *Config {
+	if node == "" {
+		return &Config{srv: srv, path: "/_config"}
+	}
+	return &Config{srv: srv, path: queryURL("_node", node, "_config")}
+}
+
+// LocalConfig returns configuration of the node serving requests, choosing
+// endpoint by server version
+func (srv *Server) LocalConfig() (*Config, error) {
+	info, err := srv.Info()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(info.Version, "1.") {
+		return srv.Config(""), nil
+	}
+	return srv.Config(LocalNode), nil
+}
+
+func (c *Config) url(section, key string) string {
+	URL := c.path
+	if section != "" {
+		URL += "/" + url.PathEscape(section)
+	}
+	if key != "" {
+		URL += "/" + url.PathEscape(key)
+	}
+	return URL
+}
+
+// All returns all configuration sections
+func (c *Config) All() (map[string]map[string]string, error) {
+	resp, err := c.srv.conn.request("GET", c.url("", ""), nil, nil, c.srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]map[string]string
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Section returns all keys of configuration section
+func (c *Config) Section(section string) (map[string]string, error) {
+	resp, err := c.srv.conn.request("GET", c.url(section, ""), nil, nil, c.srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]string
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Get returns value of configuration key
+func (c *Config) Get(section, key string) (string, error) {
+	resp, err := c.srv.conn.request("GET", c.url(section, key), nil, nil, c.srv.auth, 0)
+	if err != nil {
+		return "", err
+	}
+	var out string
+	if err := parseBody(resp, &out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// Set updates configuration key and returns its previous value
+func (c *Config) Set(section, key, value string) (string, error) {
+	headers := map[string]string{"Content-Type": appJSON}
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.srv.conn.request("PUT", c.url(section, key), headers, bytes.NewReader(payload), c.srv.auth, 0)
+	if err != nil {
+		return "", err
+	}
+	var previous string
+	if err := parseBody(resp, &previous); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// Delete removes configuration key and returns its previous value
+func (c *Config) Delete(section, key string) (string, error) {
+	resp, err := c.srv.conn.request("DELETE", c.url(section, key), nil, nil, c.srv.auth, 0)
+	if err != nil {
+		return "", err
+	}
+	var previous string
+	if err := parseBody(resp, &previous); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// Reload makes node reread configuration files (CouchDB 2.x+)
+func (c *Config) Reload() error {
+	headers := map[string]string{"Content-Type": appJSON}
+	resp, err := c.srv.conn.request("POST", c.path+"/_reload", headers, nil, c.srv.auth, 0)
+	if err != nil {
+		return err
+	}
+	var result map[string]interface{}
+	return parseBody(resp, &result)
+}
+
+// MaxDocumentSize returns `couchdb/max_document_size` in bytes
+func (c *Config) MaxDocumentSize() (int64, error) {
+	value, err := c.Get("couchdb", "max_document_size")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// SetMaxDocumentSize sets `couchdb/max_document_size` in bytes
+func (c *Config) SetMaxDocumentSize(size int64) error {
+	_, err := c.Set("couchdb", "max_document_size", strconv.FormatInt(size, 10))
+	return err
+}
+
+// RequireValidUser returns `chttpd/require_valid_user`
+func (c *Config) RequireValidUser() (bool, error) {
+	value, err := c.Get("chttpd", "require_valid_user")
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(value)
+}
+
+// SetRequireValidUser sets `chttpd/require_valid_user`
+func (c *Config) SetRequireValidUser(required bool) error {
+	_, err := c.Set("chttpd", "require_valid_user", strconv.FormatBool(required))
+	return err
+}
+
+// AuthTimeout returns `couch_httpd_auth/timeout`, lifetime of cookie sessions
+func (c *Config) AuthTimeout() (time.Duration, error) {
+	value, err := c.Get("couch_httpd_auth", "timeout")
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SetAuthTimeout sets `couch_httpd_auth/timeout`, value is rounded to seconds
+func (c *Config) SetAuthTimeout(timeout time.Duration) error {
+	_, err := c.Set("couch_httpd_auth", "timeout", strconv.FormatInt(int64(timeout/time.Second), 10))
+	return err
+}
@@ -0,0 +1,68 @@
+package gocouch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Set(t *testing.T) {
+	srv := getConnection(t)
+	config, err := srv.LocalConfig()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if _, err := config.Set("gocouch_test", "key", "value"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if value, err := config.Get("gocouch_test", "key"); err != nil || value != "value" {
+		t.Logf("Error: %v, value: %q\n", err, value)
+		t.Fail()
+		return
+	}
+	section, err := config.Section("gocouch_test")
+	if err != nil || section["key"] != "value" {
+		t.Logf("Error: %v, section: %v\n", err, section)
+		t.Fail()
+		return
+	}
+	all, err := config.All()
+	if err != nil || all["gocouch_test"]["key"] != "value" {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if previous, err := config.Delete("gocouch_test", "key"); err != nil || previous != "value" {
+		t.Logf("Error: %v, previous: %q\n", err, previous)
+		t.Fail()
+	}
+}
+
+func TestConfig_AuthTimeout(t *testing.T) {
+	srv := getConnection(t)
+	config, err := srv.LocalConfig()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	timeout, err := config.AuthTimeout()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if err := config.SetAuthTimeout(15 * time.Minute); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer config.SetAuthTimeout(timeout)
+	if updated, err := config.AuthTimeout(); err != nil || updated != 15*time.Minute {
+		t.Logf("Error: %v, timeout: %v\n", err, updated)
+		t.Fail()
+	}
+}
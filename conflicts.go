@@ -0,0 +1,366 @@
+package gocouch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConflictsDesign is a design document with view listing conflicted
+// documents, it's created by ConflictedDocsView on demand
+const ConflictsDesign = "gocouch_conflicts"
+
+const conflictsMap = `function(doc) {
+  if (doc._conflicts) {
+    emit(doc._id, {rev: doc._rev, conflicts: doc._conflicts});
+  }
+}`
+
+// ErrNoResolution is returned when resolver fails to choose the winner
+var ErrNoResolution = errors.New("Conflict resolver returned no document")
+
+// Conflict describes document having conflicting revisions, Rev is the
+// winning revision chosen by the server
+type Conflict struct {
+	ID        string   `json:"_id"`
+	Rev       string   `json:"_rev"`
+	Conflicts []string `json:"_conflicts"`
+}
+
+// ResolveResult describes result of conflict resolution of a document
+type ResolveResult struct {
+	ID  string
+	Rev string
+	Err error
+}
+
+// Resolver merges conflicting leaf revisions of a document, the first one
+// is the current winner. Returned document is saved on top of the winner,
+// `_id`, `_rev` and `_attachments` are taken from the winner
+type Resolver interface {
+	Resolve(revisions []map[string]interface{}) (map[string]interface{}, error)
+}
+
+// ResolverFunc allows to use ordinary function as Resolver
+type ResolverFunc func(revisions []map[string]interface{}) (map[string]interface{}, error)
+
+// Resolve calls f(revisions)
+func (f ResolverFunc) Resolve(revisions []map[string]interface{}) (map[string]interface{}, error) {
+	return f(revisions)
+}
+
+// RevWins picks revision the same way as the server does: the longest
+// history wins and ties are broken by greater revision hash, so every
+// client resolves the conflict identically
+type RevWins struct{}
+
+// Resolve returns the winning revision
+func (RevWins) Resolve(revisions []map[string]interface{}) (map[string]interface{}, error) {
+	var winner map[string]interface{}
+	for _, rev := range revisions {
+		if winner == nil || compareRevs(docRev(rev), docRev(winner)) > 0 {
+			winner = rev
+		}
+	}
+	if winner == nil {
+		return nil, ErrNoResolution
+	}
+	return winner, nil
+}
+
+// LatestTimestampWins picks revision with the latest timestamp stored in
+// Field as RFC 3339 string or unix time in seconds. Revisions without
+// timestamp lose, ties are resolved with RevWins
+type LatestTimestampWins struct {
+	Field string
+}
+
+// Resolve returns revision with the latest timestamp
+func (r LatestTimestampWins) Resolve(revisions []map[string]interface{}) (map[string]interface{}, error) {
+	var (
+		winner map[string]interface{}
+		latest time.Time
+	)
+	for _, rev := range revisions {
+		ts := docTimestamp(rev[r.Field])
+		if winner == nil || ts.After(latest) ||
+			(ts.Equal(latest) && compareRevs(docRev(rev), docRev(winner)) > 0) {
+			winner, latest = rev, ts
+		}
+	}
+	if winner == nil {
+		return nil, ErrNoResolution
+	}
+	return winner, nil
+}
+
+// FieldMerge merges revisions field by field. Fields equal in all revisions
+// or present in one of them are kept, for conflicting values merge function
+// from Fields is used, or the value of revision chosen by Fallback (RevWins
+// by default)
+type FieldMerge struct {
+	Fields   map[string]func(values []interface{}) interface{}
+	Fallback Resolver
+}
+
+// Resolve returns merged document
+func (r FieldMerge) Resolve(revisions []map[string]interface{}) (map[string]interface{}, error) {
+	fallback := r.Fallback
+	if fallback == nil {
+		fallback = RevWins{}
+	}
+	preferred, err := fallback.Resolve(revisions)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]interface{})
+	for _, rev := range revisions {
+		for k, v := range rev {
+			if !strings.HasPrefix(k, "_") {
+				values[k] = append(values[k], v)
+			}
+		}
+	}
+	merged := make(map[string]interface{}, len(values))
+	for k, vs := range values {
+		if allEqual(vs) {
+			merged[k] = vs[0]
+		} else if merge := r.Fields[k]; merge != nil {
+			merged[k] = merge(vs)
+		} else if v, ok := preferred[k]; ok {
+			// value of preferred revision, fields it lacks are dropped
+			merged[k] = v
+		}
+	}
+	return merged, nil
+}
+
+func allEqual(values []interface{}) bool {
+	for _, v := range values[1:] {
+		if !reflect.DeepEqual(v, values[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func docRev(doc map[string]interface{}) string {
+	rev, _ := doc["_rev"].(string)
+	return rev
+}
+
+// compareRevs compares revisions by generation and then by hash
+func compareRevs(a, b string) int {
+	pos := func(rev string) (int, string) {
+		parts := strings.SplitN(rev, "-", 2)
+		n, _ := strconv.Atoi(parts[0])
+		if len(parts) < 2 {
+			return n, ""
+		}
+		return n, parts[1]
+	}
+	aPos, aHash := pos(a)
+	bPos, bHash := pos(b)
+	if aPos != bPos {
+		if aPos > bPos {
+			return 1
+		}
+		return -1
+	}
+	return strings.Compare(aHash, bHash)
+}
+
+func docTimestamp(v interface{}) time.Time {
+	switch ts := v.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err == nil {
+			return t
+		}
+	case float64:
+		return time.Unix(0, int64(ts*float64(time.Second)))
+	}
+	return time.Time{}
+}
+
+// ConflictedDocs lists documents having conflicts with Mango query, it
+// scans the whole database (CouchDB 2.x+)
+func (db *Database) ConflictedDocs() ([]Conflict, error) {
+	const pageSize = 1000
+	query := map[string]interface{}{
+		"selector":  map[string]interface{}{"_conflicts": map[string]bool{"$exists": true}},
+		"fields":    []string{"_id", "_rev", "_conflicts"},
+		"conflicts": true,
+		"limit":     pageSize,
+	}
+	var conflicts []Conflict
+	for {
+		payload, err := json.Marshal(query)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := db.conn.request("POST", queryURL(db.Name, "_find"), map[string]string{"Content-Type": appJSON},
+			bytes.NewReader(payload), db.auth, 0)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Docs     []Conflict `json:"docs"`
+			Bookmark string     `json:"bookmark"`
+		}
+		if err := parseBody(resp, &result); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, result.Docs...)
+		if len(result.Docs) < pageSize || result.Bookmark == "" {
+			return conflicts, nil
+		}
+		query["bookmark"] = result.Bookmark
+	}
+}
+
+// ConflictedDocsView lists documents having conflicts with a view from
+// ConflictsDesign design document, which is created when missing. Unlike
+// ConflictedDocs it works with CouchDB 1.x and indexes changes incrementally
+func (db *Database) ConflictedDocsView() ([]Conflict, error) {
+	if _, err := db.DesignInfo(ConflictsDesign); isStatus(err, 404) {
+		ddoc := map[string]interface{}{
+			"language": "javascript",
+			"views":    map[string]interface{}{"conflicts": map[string]string{"map": conflictsMap}},
+		}
+		if _, err := db.Put("_design/"+ConflictsDesign, ddoc); err != nil && !isStatus(err, 409) {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	resp, err := db.conn.request("GET", queryURL(db.Name, "_design", ConflictsDesign, "_view", "conflicts"),
+		nil, nil, db.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Rows []struct {
+			ID    string `json:"id"`
+			Value struct {
+				Rev       string   `json:"rev"`
+				Conflicts []string `json:"conflicts"`
+			} `json:"value"`
+		} `json:"rows"`
+	}
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	conflicts := make([]Conflict, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		conflicts = append(conflicts, Conflict{ID: row.ID, Rev: row.Value.Rev, Conflicts: row.Value.Conflicts})
+	}
+	return conflicts, nil
+}
+
+// LeafRevisions returns the winning revision of the document followed by
+// conflicting ones, deleted leafs are not included
+func (db *Database) LeafRevisions(id string) ([]map[string]interface{}, error) {
+	var winner map[string]interface{}
+	if err := db.Get(docPath(id), &winner, Options{"conflicts": true}); err != nil {
+		return nil, err
+	}
+	revisions := []map[string]interface{}{winner}
+	conflicts, _ := winner["_conflicts"].([]interface{})
+	delete(winner, "_conflicts")
+	if len(conflicts) == 0 {
+		return revisions, nil
+	}
+	openRevs, err := json.Marshal(conflicts)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := db.conn.request("GET", queryURL(db.Name, docPath(id))+encodeOptions(Options{"open_revs": string(openRevs)}),
+		map[string]string{"Accept": appJSON}, nil, db.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var result []struct {
+		Ok map[string]interface{} `json:"ok"`
+	}
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	for _, item := range result {
+		if item.Ok != nil && item.Ok["_deleted"] != true {
+			revisions = append(revisions, item.Ok)
+		}
+	}
+	return revisions, nil
+}
+
+// ResolveConflicts resolves conflicts of the document with resolver, and
+// saves the result on top of the winning revision deleting the losing ones
+// in a single `_bulk_docs` request. New revision is returned, it's the
+// current one when document has no conflicts
+func (db *Database) ResolveConflicts(id string, resolver Resolver) (string, error) {
+	revisions, err := db.LeafRevisions(id)
+	if err != nil {
+		return "", err
+	}
+	base := revisions[0]
+	if len(revisions) == 1 {
+		return docRev(base), nil
+	}
+	resolved, err := resolver.Resolve(revisions)
+	if err != nil {
+		return "", err
+	}
+	if resolved == nil {
+		return "", ErrNoResolution
+	}
+	doc := make(map[string]interface{}, len(resolved))
+	for k, v := range resolved {
+		if !strings.HasPrefix(k, "_") {
+			doc[k] = v
+		}
+	}
+	doc["_id"], doc["_rev"] = id, docRev(base)
+	if attachments, ok := base["_attachments"]; ok {
+		doc["_attachments"] = attachments
+	}
+	docs := []map[string]interface{}{doc}
+	for _, rev := range revisions[1:] {
+		docs = append(docs, map[string]interface{}{"_id": id, "_rev": docRev(rev), "_deleted": true})
+	}
+	results, err := db.Update(docs, false, true, false)
+	if err != nil {
+		return "", err
+	}
+	var rev string
+	for i, result := range results {
+		if result.Error != "" {
+			return "", bulkError(result.Error, result.Reason)
+		}
+		if i == 0 {
+			rev = result.Rev
+		}
+	}
+	return rev, nil
+}
+
+// ResolveAllConflicts resolves conflicts of every conflicted document found
+// by ConflictedDocs, results are sorted by id
+func (db *Database) ResolveAllConflicts(resolver Resolver) ([]ResolveResult, error) {
+	conflicts, err := db.ConflictedDocs()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].ID < conflicts[j].ID })
+	results := make([]ResolveResult, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		rev, err := db.ResolveConflicts(conflict.ID, resolver)
+		results = append(results, ResolveResult{ID: conflict.ID, Rev: rev, Err: err})
+	}
+	return results, nil
+}
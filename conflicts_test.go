@@ -0,0 +1,107 @@
+package gocouch
+
+import (
+	"testing"
+)
+
+func conflictRevisions() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"_id": "doc", "_rev": "2-b", "name": "milk", "count": 1.0, "updated": "2024-01-02T00:00:00Z"},
+		{"_id": "doc", "_rev": "2-c", "name": "milk", "count": 2.0, "updated": "2024-01-01T00:00:00Z", "extra": true},
+		{"_id": "doc", "_rev": "1-z", "name": "old", "count": 3.0},
+	}
+}
+
+func TestCompareRevs(t *testing.T) {
+	if compareRevs("10-a", "9-b") <= 0 || compareRevs("2-a", "2-b") >= 0 || compareRevs("2-a", "2-a") != 0 {
+		t.Log("Unexpected revision order")
+		t.Fail()
+	}
+}
+
+func TestResolvers(t *testing.T) {
+	winner, err := RevWins{}.Resolve(conflictRevisions())
+	if err != nil || docRev(winner) != "2-c" {
+		t.Logf("Error: %v, winner: %v\n", err, winner)
+		t.Fail()
+	}
+	winner, err = LatestTimestampWins{Field: "updated"}.Resolve(conflictRevisions())
+	if err != nil || docRev(winner) != "2-b" {
+		t.Logf("Error: %v, winner: %v\n", err, winner)
+		t.Fail()
+	}
+	merge := FieldMerge{Fields: map[string]func([]interface{}) interface{}{
+		"count": func(values []interface{}) interface{} {
+			sum := 0.0
+			for _, v := range values {
+				sum += v.(float64)
+			}
+			return sum
+		},
+	}}
+	merged, err := merge.Resolve(conflictRevisions())
+	if err != nil || merged["count"] != 6.0 || merged["name"] != "milk" || merged["extra"] != true {
+		t.Logf("Error: %v, merged: %v\n", err, merged)
+		t.Fail()
+	}
+	if _, ok := merged["_rev"]; ok {
+		t.Log("Special fields must not be merged")
+		t.Fail()
+	}
+	if _, err := (RevWins{}).Resolve(nil); err != ErrNoResolution {
+		t.Logf("Expected ErrNoResolution, got: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestDatabase_ResolveConflicts(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("conflicts_test", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	// conflicting branches are created the way replication does
+	docs := []map[string]interface{}{
+		{"_id": "doc", "_rev": "1-aaa", "count": 1},
+		{"_id": "doc", "_rev": "1-bbb", "count": 2},
+		{"_id": "doc", "_rev": "1-ccc", "count": 3},
+	}
+	if _, err := db.Update(docs, false, false, false); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	conflicts, err := db.ConflictedDocsView()
+	if err != nil || len(conflicts) != 1 || len(conflicts[0].Conflicts) != 2 {
+		t.Logf("Error: %v, conflicts: %+v\n", err, conflicts)
+		t.Fail()
+		return
+	}
+	revisions, err := db.LeafRevisions("doc")
+	if err != nil || len(revisions) != 3 || docRev(revisions[0]) != "1-ccc" {
+		t.Logf("Error: %v, revisions: %v\n", err, revisions)
+		t.Fail()
+		return
+	}
+	lowest := ResolverFunc(func(revisions []map[string]interface{}) (map[string]interface{}, error) {
+		return revisions[len(revisions)-1], nil
+	})
+	rev, err := db.ResolveConflicts("doc", lowest)
+	if err != nil || rev[:2] != "2-" {
+		t.Logf("Error: %v, rev: %v\n", err, rev)
+		t.Fail()
+		return
+	}
+	revisions, err = db.LeafRevisions("doc")
+	if err != nil || len(revisions) != 1 || docRev(revisions[0]) != rev {
+		t.Logf("Error: %v, revisions: %v\n", err, revisions)
+		t.Fail()
+	}
+	if results, err := db.ResolveAllConflicts(RevWins{}); err != nil || len(results) != 0 {
+		t.Logf("Error: %v, results: %v\n", err, results)
+		t.Fail()
+	}
+}
@@ -0,0 +1,213 @@
+package gocouch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CheckpointStore persists position of a changes feed consumer
+type CheckpointStore interface {
+	// LoadCheckpoint returns last saved sequence or empty one if nothing was saved yet
+	LoadCheckpoint(id string) (Sequence, error)
+	// SaveCheckpoint stores sequence under given id
+	SaveCheckpoint(id string, seq Sequence) error
+}
+
+// LocalCheckpointStore keeps checkpoints in `_local/` documents of a database,
+// such documents are not replicated and do not appear in changes feed
+type LocalCheckpointStore struct {
+	db   *Database
+	mu   sync.Mutex
+	revs map[string]string
+}
+
+// ChangesConsumer follows the changes feed of a database, passes events to the
+// handler and periodically saves the last processed sequence to the
+// CheckpointStore, so a restarted consumer continues from where the previous
+// one stopped. Delivery is at-least-once: events processed after the last
+// saved checkpoint will be delivered again after restart.
+//
+// Either OnChange or OnBatch must be set, returned error stops the consumer
+type ChangesConsumer struct {
+	db *Database
+	// ID identifies the checkpoint of this consumer
+	ID string
+	// Store persists checkpoints, defaults to LocalCheckpointStore of the database
+	Store CheckpointStore
+	// OnChange is called for every event of the feed
+	OnChange func(Change) error
+	// OnBatch is called for every non-empty batch of events
+	OnBatch func([]Change) error
+	// BatchSize limits count of events fetched per request
+	BatchSize int
+	// CheckpointInterval is a minimal period between checkpoint writes,
+	// zero value means saving after every batch
+	CheckpointInterval time.Duration
+	// PollTimeout is a time server waits for new events before answering
+	PollTimeout time.Duration
+	// Options are passed to the `_changes` request (e.g. filter, include_docs)
+	Options Options
+}
+
+type changesBatch struct {
+	Results []Change `json:"results"`
+	LastSeq Sequence `json:"last_seq"`
+}
+
+type checkpointDoc struct {
+	ID        string   `json:"_id"`
+	Rev       string   `json:"_rev,omitempty"`
+	Seq       Sequence `json:"seq"`
+	UpdatedOn int64    `json:"updated_on"`
+}
+
+// NewLocalCheckpointStore returns checkpoint store backed by given database
+func NewLocalCheckpointStore(db *Database) *LocalCheckpointStore {
+	return &LocalCheckpointStore{db: db, revs: make(map[string]string)}
+}
+
+// LoadCheckpoint fetches `_local/<id>` document and returns sequence stored in it
+func (s *LocalCheckpointStore) LoadCheckpoint(id string) (Sequence, error) {
+	var doc checkpointDoc
+	if err := s.db.Get("_local/"+id, &doc, nil); err != nil {
+		if isStatus(err, 404) {
+			return "", nil
+		}
+		return "", err
+	}
+	s.mu.Lock()
+	s.revs[id] = doc.Rev
+	s.mu.Unlock()
+	return doc.Seq, nil
+}
+
+// SaveCheckpoint writes sequence to `_local/<id>` document
+func (s *LocalCheckpointStore) SaveCheckpoint(id string, seq Sequence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc := checkpointDoc{
+		ID:        "_local/" + id,
+		Rev:       s.revs[id],
+		Seq:       seq,
+		UpdatedOn: time.Now().Unix(),
+	}
+	rev, err := s.db.Put(doc.ID, &doc)
+	if err != nil {
+		return err
+	}
+	s.revs[id] = rev
+	return nil
+}
+
+// NewChangesConsumer returns consumer of current database changes with
+// checkpoints stored in `_local/<id>` document
+func (db *Database) NewChangesConsumer(id string) *ChangesConsumer {
+	return &ChangesConsumer{
+		db:          db,
+		ID:          id,
+		Store:       NewLocalCheckpointStore(db),
+		BatchSize:   100,
+		PollTimeout: 30 * time.Second,
+	}
+}
+
+// Run loads the checkpoint and processes the feed until context is cancelled
+// or handler returns an error. Progress made so far is saved before return
+func (c *ChangesConsumer) Run(ctx context.Context) error {
+	if c.OnChange == nil && c.OnBatch == nil {
+		return errors.New("Consumer has no handler")
+	}
+	since, err := c.Store.LoadCheckpoint(c.ID)
+	if err != nil {
+		return err
+	}
+	saved, savedAt := since, time.Now()
+	save := func() error {
+		if since == saved {
+			return nil
+		}
+		if err := c.Store.SaveCheckpoint(c.ID, since); err != nil {
+			return err
+		}
+		saved, savedAt = since, time.Now()
+		return nil
+	}
+	conn := c.db.conn.streaming()
+	for {
+		batch, err := c.poll(ctx, conn, since)
+		if err != nil {
+			if ctx.Err() != nil {
+				if err := save(); err != nil {
+					return err
+				}
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := c.handle(batch.Results, &since); err != nil {
+			if err := save(); err != nil {
+				return err
+			}
+			return err
+		}
+		if batch.LastSeq != "" {
+			since = batch.LastSeq
+		}
+		if time.Since(savedAt) >= c.CheckpointInterval {
+			if err := save(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle passes events to the handler and moves since forward on success
+func (c *ChangesConsumer) handle(events []Change, since *Sequence) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if c.OnBatch != nil {
+		if err := c.OnBatch(events); err != nil {
+			return err
+		}
+		*since = events[len(events)-1].Seq
+		return nil
+	}
+	for _, event := range events {
+		if err := c.OnChange(event); err != nil {
+			return err
+		}
+		*since = event.Seq
+	}
+	return nil
+}
+
+// poll requests next batch of events using longpoll feed
+func (c *ChangesConsumer) poll(ctx context.Context, conn *connection, since Sequence) (*changesBatch, error) {
+	options := Options{"feed": "longpoll"}
+	for k, v := range c.Options {
+		options[k] = v
+	}
+	if since != "" {
+		options["since"] = since
+	}
+	if c.BatchSize > 0 {
+		options["limit"] = c.BatchSize
+	}
+	if c.PollTimeout > 0 {
+		options["timeout"] = fmt.Sprint(int64(c.PollTimeout / time.Millisecond))
+	}
+	resp, err := conn.requestContext(ctx, "GET",
+		queryURL(c.db.Name, "_changes")+encodeOptions(options), nil, nil, c.db.auth)
+	if err != nil {
+		return nil, err
+	}
+	var batch changesBatch
+	if err := parseBody(resp, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
@@ -0,0 +1,69 @@
+package gocouch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChangesConsumer_Run(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("changes_consumer", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	for i := 0; i < 5; i++ {
+		if _, _, err := db.Insert(map[string]int{"value": i}, false, false); err != nil {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var received int
+	consumer := db.NewChangesConsumer("test_consumer")
+	consumer.BatchSize = 2
+	consumer.PollTimeout = time.Second
+	consumer.OnChange = func(c Change) error {
+		received++
+		if received == 5 {
+			cancel()
+		}
+		return nil
+	}
+	if err := consumer.Run(ctx); err != context.Canceled {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if received != 5 {
+		t.Logf("Expected 5 events, got %d\n", received)
+		t.Fail()
+		return
+	}
+	// restarted consumer must continue from the checkpoint
+	seq, err := consumer.Store.LoadCheckpoint("test_consumer")
+	if err != nil || seq == "" {
+		t.Logf("Error: %v, checkpoint: %q\n", err, seq)
+		t.Fail()
+		return
+	}
+	db.Insert(map[string]int{"value": 5}, false, false)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	restarted := db.NewChangesConsumer("test_consumer")
+	restarted.OnBatch = func(events []Change) error {
+		received += len(events)
+		cancel()
+		return nil
+	}
+	restarted.Run(ctx)
+	if received != 6 {
+		t.Logf("Expected single event after restart, got %d\n", received-5)
+		t.Fail()
+	}
+}
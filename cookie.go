@@ -0,0 +1,204 @@
+package gocouch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const sessionCookie = "AuthSession"
+
+// Session authentication errors
+var (
+	ErrInvalidCredentials = errors.New("Name or password is incorrect")
+	ErrNoSessionCookie    = errors.New("Server did not return session cookie")
+	ErrNoSession          = errors.New("Session is not started")
+	ErrSessionExpired     = errors.New("Session is expired")
+	ErrSessionServer      = errors.New("Session belongs to another server")
+)
+
+// SessionToken is a serializable session cookie, it allows to reuse
+// session between runs of short living programs. Expires is zero unless
+// server issues persistent cookies
+type SessionToken struct {
+	Cookie    string    `json:"cookie"`
+	Expires   time.Time `json:"expires,omitempty"`
+	ServerURL string    `json:"server_url"`
+}
+
+// renewer is implemented by Auth which maintains credentials itself, it's
+// asked to authorize every request and sees every response, so it can
+// refresh credentials and ask for the request to be replayed
+type renewer interface {
+	Auth
+	authorize(conn *connection, req *http.Request) error
+	handleResponse(conn *connection, req *http.Request, resp *http.Response, replay bool) (bool, error)
+}
+
+// CookieAuth authenticates requests with session cookie. It logs in on the
+// first request, picks up cookies refreshed by the server and logs in again
+// when the session expires, replaying the rejected request. It's safe for
+// concurrent use
+type CookieAuth struct {
+	Username, Password string
+	mu                 sync.Mutex
+	cookie             *http.Cookie
+	// url of the server issued the cookie
+	url string
+}
+
+// NewCookieAuth returns session authentication for given credentials
+func NewCookieAuth(username, password string) *CookieAuth {
+	return &CookieAuth{Username: username, Password: password}
+}
+
+// AddAuthHeaders adds current session cookie to request if there is one
+func (a *CookieAuth) AddAuthHeaders(req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cookie != nil {
+		req.AddCookie(a.cookie)
+	}
+}
+
+// Login starts new session at the server, it's done automatically so it's
+// only needed to check credentials in advance
+func (srv *Server) Login(a *CookieAuth) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.login(srv.conn)
+}
+
+func (a *CookieAuth) authorize(conn *connection, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cookie == nil || (!a.cookie.Expires.IsZero() && time.Now().After(a.cookie.Expires)) {
+		if err := a.login(conn); err != nil {
+			return err
+		}
+	}
+	req.AddCookie(a.cookie)
+	return nil
+}
+
+func (a *CookieAuth) handleResponse(conn *connection, req *http.Request, resp *http.Response, replay bool) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if resp.StatusCode != http.StatusUnauthorized {
+		if cookie := responseCookie(resp); cookie != nil {
+			a.cookie, a.url = cookie, conn.url
+		}
+		return false, nil
+	}
+	if !replay {
+		return false, nil
+	}
+	// other request may have already renewed the session
+	sent, err := req.Cookie(sessionCookie)
+	if err == nil && a.cookie != nil && sent.Value != a.cookie.Value {
+		return true, nil
+	}
+	if err := a.login(conn); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// login obtains new session cookie, caller must hold the lock
+func (a *CookieAuth) login(conn *connection) error {
+	a.cookie = nil
+	payload, err := json.Marshal(map[string]string{"name": a.Username, "password": a.Password})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", conn.url+"/_session", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", appJSON)
+	resp, err := conn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return ErrInvalidCredentials
+	}
+	if resp.StatusCode >= 400 {
+		return parseError(resp)
+	}
+	cookie := responseCookie(resp)
+	if err := resp.Body.Close(); err != nil {
+		return err
+	}
+	if cookie == nil {
+		return ErrNoSessionCookie
+	}
+	a.cookie, a.url = cookie, conn.url
+	return nil
+}
+
+// Export returns token of current session
+func (a *CookieAuth) Export() (*SessionToken, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cookie == nil {
+		return nil, ErrNoSession
+	}
+	return newSessionToken(a.cookie, a.url), nil
+}
+
+// Export returns token of the session
+func (s *Session) Export() (*SessionToken, error) {
+	if s.cookie == nil {
+		return nil, ErrNoSession
+	}
+	return newSessionToken(s.cookie, s.srv.conn.url), nil
+}
+
+func newSessionToken(cookie *http.Cookie, url string) *SessionToken {
+	return &SessionToken{Cookie: cookie.Value, Expires: cookie.Expires, ServerURL: url}
+}
+
+// RestoreSession returns cookie authentication continuing exported session.
+// Session is checked with the server, when it's expired or rejected new one
+// is started with given credentials, if password is empty ErrSessionExpired
+// is returned instead. Non-empty username must match session user
+func (srv *Server) RestoreSession(token *SessionToken, username, password string) (*CookieAuth, error) {
+	if token.ServerURL != srv.conn.url {
+		return nil, ErrSessionServer
+	}
+	auth := NewCookieAuth(username, password)
+	cookie := &http.Cookie{Name: sessionCookie, Value: token.Cookie, Expires: token.Expires}
+	if token.Expires.IsZero() || time.Now().Before(token.Expires) {
+		info, err := sessionInfo(srv.conn, Session{cookie: cookie})
+		if err != nil && !isStatus(err, 400) && !isStatus(err, 401) {
+			return nil, err
+		}
+		if err == nil && info.UserCtx.Name != "" && (username == "" || info.UserCtx.Name == username) {
+			auth.Username = info.UserCtx.Name
+			auth.cookie, auth.url = cookie, srv.conn.url
+			return auth, nil
+		}
+	}
+	if password == "" {
+		return nil, ErrSessionExpired
+	}
+	if err := srv.Login(auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// responseCookie returns session cookie set by response
+func responseCookie(resp *http.Response) *http.Cookie {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
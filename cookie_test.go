@@ -0,0 +1,116 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCookieAuth_AddAuthHeaders(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("cookie_auth_test", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	auth := NewCookieAuth("admin", "admin")
+	db, err = srv.GetDatabase("cookie_auth_test", auth)
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := db.Insert(map[string]string{"field": "value"}, false, false); err != nil {
+				t.Logf("Error: %v\n", err)
+				t.Fail()
+			}
+		}()
+	}
+	wg.Wait()
+	if auth.cookie == nil {
+		t.Log("Session cookie is not set")
+		t.Fail()
+	}
+}
+
+func TestServer_Login(t *testing.T) {
+	srv := getConnection(t)
+	if err := srv.Login(NewCookieAuth("admin", "wrong")); err != ErrInvalidCredentials {
+		t.Logf("Expected ErrInvalidCredentials, got: %v\n", err)
+		t.Fail()
+	}
+	if err := srv.Login(NewCookieAuth("admin", "admin")); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestServer_RestoreSession(t *testing.T) {
+	srv := getConnection(t)
+	auth := NewCookieAuth("admin", "admin")
+	if _, err := auth.Export(); err != ErrNoSession {
+		t.Logf("Expected ErrNoSession, got: %v\n", err)
+		t.Fail()
+	}
+	if err := srv.Login(auth); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	token, err := auth.Export()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	var restored SessionToken
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	// no password is needed to continue valid session
+	restoredAuth, err := srv.RestoreSession(&restored, "admin", "")
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if _, err := srv.WithAuth(restoredAuth).GetAllDBs(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+	restored.Cookie = "YWRtaW46NUI1NDAwMDA6invalid"
+	if _, err := srv.RestoreSession(&restored, "admin", "admin"); err != nil {
+		t.Logf("Expected re-login, got: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestServer_RestoreSessionExpired(t *testing.T) {
+	srv, _ := Connect("localhost", 5984, nil, 0)
+	token := &SessionToken{Cookie: "value", Expires: time.Now().Add(-time.Minute), ServerURL: srv.conn.url}
+	if _, err := srv.RestoreSession(token, "admin", ""); err != ErrSessionExpired {
+		t.Logf("Expected ErrSessionExpired, got: %v\n", err)
+		t.Fail()
+	}
+	token.ServerURL = "http://example.com:5984"
+	if _, err := srv.RestoreSession(token, "admin", "admin"); err != ErrSessionServer {
+		t.Logf("Expected ErrSessionServer, got: %v\n", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,295 @@
+package gocouch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentials is returned by providers which have no credentials to offer
+var ErrNoCredentials = errors.New("Credentials not found")
+
+// Credentials are user name and password used for basic authentication
+type Credentials struct {
+	Username, Password string
+}
+
+// CredentialsProvider loads credentials from an external source
+type CredentialsProvider interface {
+	Credentials() (*Credentials, error)
+}
+
+// CredentialsWatcher is implemented by providers able to detect that
+// credentials were changed since they were loaded last time
+type CredentialsWatcher interface {
+	Changed() bool
+}
+
+// CredentialsAuth is basic authentication with credentials loaded from the
+// Provider. They are cached and reloaded when provider reports a change or
+// the server rejects them, in which case request is replayed. It's safe for
+// concurrent use
+type CredentialsAuth struct {
+	Provider CredentialsProvider
+	mu       sync.Mutex
+	creds    *Credentials
+}
+
+// NewCredentialsAuth returns basic authentication backed by given provider
+func NewCredentialsAuth(provider CredentialsProvider) *CredentialsAuth {
+	return &CredentialsAuth{Provider: provider}
+}
+
+// AddAuthHeaders adds cached credentials to the request if there are any
+func (a *CredentialsAuth) AddAuthHeaders(req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.creds != nil {
+		BasicAuth{a.creds.Username, a.creds.Password}.AddAuthHeaders(req)
+	}
+}
+
+func (a *CredentialsAuth) authorize(conn *connection, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	watcher, ok := a.Provider.(CredentialsWatcher)
+	if a.creds == nil || (ok && watcher.Changed()) {
+		if err := a.reload(); err != nil {
+			return err
+		}
+	}
+	req.Header.Del("Authorization")
+	BasicAuth{a.creds.Username, a.creds.Password}.AddAuthHeaders(req)
+	return nil
+}
+
+func (a *CredentialsAuth) handleResponse(conn *connection, req *http.Request, resp *http.Response, replay bool) (bool, error) {
+	if resp.StatusCode != http.StatusUnauthorized || !replay {
+		return false, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// other request may have already reloaded credentials
+	user, pass, _ := req.BasicAuth()
+	if a.creds != nil && (user != a.creds.Username || pass != a.creds.Password) {
+		return true, nil
+	}
+	if err := a.reload(); err != nil {
+		return false, err
+	}
+	// replaying with the same credentials would fail again
+	return user != a.creds.Username || pass != a.creds.Password, nil
+}
+
+// reload loads credentials from provider, caller must hold the lock
+func (a *CredentialsAuth) reload() error {
+	creds, err := a.Provider.Credentials()
+	if err != nil {
+		return err
+	}
+	a.creds = creds
+	return nil
+}
+
+// EnvCredentials reads credentials from environment variables, COUCHDB_USER
+// and COUCHDB_PASSWORD are used by default
+type EnvCredentials struct {
+	UsernameVar, PasswordVar string
+}
+
+// Credentials returns values of environment variables
+func (e EnvCredentials) Credentials() (*Credentials, error) {
+	userVar, passVar := e.UsernameVar, e.PasswordVar
+	if userVar == "" {
+		userVar = "COUCHDB_USER"
+	}
+	if passVar == "" {
+		passVar = "COUCHDB_PASSWORD"
+	}
+	user, ok := os.LookupEnv(userVar)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return &Credentials{Username: user, Password: os.Getenv(passVar)}, nil
+}
+
+// fileWatch detects changes of a file by its modification time and size,
+// file is checked not more often than once per interval
+type fileWatch struct {
+	mu      sync.Mutex
+	checked time.Time
+	modTime time.Time
+	size    int64
+}
+
+// changed reports whether file differs from the one seen last time
+func (w *fileWatch) changed(path string, interval time.Duration) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if time.Since(w.checked) < interval {
+		return false
+	}
+	w.checked = time.Now()
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+// read reads the file remembering its state
+func (w *fileWatch) read(path string) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w.checked, w.modTime, w.size = time.Now(), info.ModTime(), info.Size()
+	return data, nil
+}
+
+// FileCredentials reads credentials from a file containing "user:password",
+// e.g. mounted secret. File is checked for changes once per Interval
+type FileCredentials struct {
+	Path     string
+	Interval time.Duration
+	watch    fileWatch
+}
+
+// NewFileCredentials returns provider reading given file, which is checked
+// for changes every 10 seconds
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{Path: path, Interval: 10 * time.Second}
+}
+
+// Credentials reads credentials from the file
+func (f *FileCredentials) Credentials() (*Credentials, error) {
+	data, err := f.watch.read(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	line := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, ErrNoCredentials
+	}
+	return &Credentials{Username: parts[0], Password: parts[1]}, nil
+}
+
+// Changed reports whether file was modified
+func (f *FileCredentials) Changed() bool {
+	return f.watch.changed(f.Path, f.Interval)
+}
+
+// NetrcCredentials reads credentials of the Machine from netrc file, falling
+// back to the `default` entry. Path defaults to $NETRC or ~/.netrc
+type NetrcCredentials struct {
+	Path     string
+	Machine  string
+	Interval time.Duration
+	watch    fileWatch
+}
+
+// NewNetrcCredentials returns provider of credentials for given host, file
+// is checked for changes every 10 seconds
+func NewNetrcCredentials(machine string) *NetrcCredentials {
+	return &NetrcCredentials{Machine: machine, Interval: 10 * time.Second}
+}
+
+func (n *NetrcCredentials) path() string {
+	if n.Path != "" {
+		return n.Path
+	}
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".netrc"
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// Credentials finds the machine entry in netrc file
+func (n *NetrcCredentials) Credentials() (*Credentials, error) {
+	data, err := n.watch.read(n.path())
+	if err != nil {
+		return nil, err
+	}
+	return parseNetrc(string(data), n.Machine)
+}
+
+// Changed reports whether netrc file was modified
+func (n *NetrcCredentials) Changed() bool {
+	return n.watch.changed(n.path(), n.Interval)
+}
+
+// parseNetrc returns credentials of the machine or of the default entry
+func parseNetrc(data, machine string) (*Credentials, error) {
+	var tokens []string
+	macro := false
+	for _, line := range strings.Split(data, "\n") {
+		// macro definitions end with a blank line
+		if macro {
+			macro = strings.TrimSpace(line) != ""
+			continue
+		}
+		for _, token := range strings.Fields(line) {
+			if token == "macdef" {
+				macro = true
+				break
+			}
+			tokens = append(tokens, token)
+		}
+	}
+	var matched, fallback, current *Credentials
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			current = nil
+			if i+1 < len(tokens) {
+				i++
+				if tokens[i] == machine && matched == nil {
+					matched = &Credentials{}
+					current = matched
+				}
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &Credentials{}
+				current = fallback
+			}
+		case "login", "password", "account":
+			if i+1 >= len(tokens) {
+				continue
+			}
+			i++
+			if current == nil {
+				continue
+			}
+			if tokens[i-1] == "login" {
+				current.Username = tokens[i]
+			} else if tokens[i-1] == "password" {
+				current.Password = tokens[i]
+			}
+		}
+	}
+	if matched == nil || matched.Username == "" {
+		matched = fallback
+	}
+	if matched == nil || matched.Username == "" {
+		return nil, ErrNoCredentials
+	}
+	return matched, nil
+}
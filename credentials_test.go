@@ -0,0 +1,104 @@
+package gocouch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseNetrc(t *testing.T) {
+	data := `machine example.com login other password other_pass
+macdef init
+	cd /pub
+	machine localhost login macro password macro
+
+machine localhost
+	login milk
+	password 220162
+default login guest password guest
+`
+	creds, err := parseNetrc(data, "localhost")
+	if err != nil || creds.Username != "milk" || creds.Password != "220162" {
+		t.Logf("Unexpected credentials: %+v, error: %v\n", creds, err)
+		t.Fail()
+	}
+	creds, err = parseNetrc(data, "unknown")
+	if err != nil || creds.Username != "guest" {
+		t.Logf("Expected default credentials, got: %+v, error: %v\n", creds, err)
+		t.Fail()
+	}
+	if _, err := parseNetrc("machine example.com login a password b", "localhost"); err != ErrNoCredentials {
+		t.Logf("Expected ErrNoCredentials, got: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestEnvCredentials_Credentials(t *testing.T) {
+	os.Setenv("GOCOUCH_TEST_USER", "milk")
+	os.Setenv("GOCOUCH_TEST_PASSWORD", "220162")
+	defer os.Unsetenv("GOCOUCH_TEST_USER")
+	defer os.Unsetenv("GOCOUCH_TEST_PASSWORD")
+	creds, err := EnvCredentials{"GOCOUCH_TEST_USER", "GOCOUCH_TEST_PASSWORD"}.Credentials()
+	if err != nil || creds.Username != "milk" || creds.Password != "220162" {
+		t.Logf("Unexpected credentials: %+v, error: %v\n", creds, err)
+		t.Fail()
+	}
+	if _, err := (EnvCredentials{UsernameVar: "GOCOUCH_TEST_MISSING"}).Credentials(); err != ErrNoCredentials {
+		t.Logf("Expected ErrNoCredentials, got: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestCredentialsAuth_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocouch")
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+	if err := ioutil.WriteFile(path, []byte("milk:220162\n"), 0600); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	provider := NewFileCredentials(path)
+	provider.Interval = 0
+	auth := NewCredentialsAuth(provider)
+	req, _ := http.NewRequest("GET", "http://localhost:5984/", nil)
+	if err := auth.authorize(nil, req); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if user, pass, _ := req.BasicAuth(); user != "milk" || pass != "220162" {
+		t.Logf("Unexpected credentials: %v:%v\n", user, pass)
+		t.Fail()
+	}
+	// rejected request is not replayed until credentials change
+	retry, err := auth.handleResponse(nil, req, &http.Response{StatusCode: http.StatusUnauthorized}, true)
+	if err != nil || retry {
+		t.Logf("Unexpected retry: %v, error: %v\n", retry, err)
+		t.Fail()
+	}
+	if err := ioutil.WriteFile(path, []byte("milk:rotated_password\n"), 0600); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	req, _ = http.NewRequest("GET", "http://localhost:5984/", nil)
+	if err := auth.authorize(nil, req); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if _, pass, _ := req.BasicAuth(); pass != "rotated_password" {
+		t.Logf("Credentials were not reloaded: %v\n", pass)
+		t.Fail()
+	}
+}
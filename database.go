@@ -22,7 +22,9 @@ type Database struct {
 	Name string
 }
 
-// DBInfo describes a database information
+// DBInfo describes a database information. Sequences of CouchDB 2.x are
+// strings, numeric fields contain their numeric prefix, use accessors to get
+// the full values
 type DBInfo struct {
 	CommitedUpdateSeq int    `json:"committed_update_seq"`
 	CompactRunning    bool   `json:"compact_running"`
@@ -30,11 +32,21 @@ type DBInfo struct {
 	DiskVersion       int    `json:"disk_format_version"`
 	DataSize          int    `json:"data_size"`
 	DiskSize          int    `json:"disk_size"`
+	Sizes             Sizes  `json:"sizes"`
 	DocCount          int    `json:"doc_count"`
 	DocDelCount       int    `json:"doc_del_count"`
 	StartTime         string `json:"instance_start_time"`
 	PurgeSeq          int    `json:"purge_seq"`
 	UpdateSeq         int    `json:"update_seq"`
+
+	committedUpdateSeq, purgeSeq, updateSeq Sequence
+}
+
+// Sizes describes disk usage of a database or an index reported by CouchDB 2.x+
+type Sizes struct {
+	Active   int64 `json:"active"`
+	External int64 `json:"external"`
+	File     int64 `json:"file"`
 }
 
 // ViewResult contains result of a view request
@@ -47,9 +59,11 @@ type ViewResult struct {
 
 // UpdateResult contains information about bulk request
 type UpdateResult struct {
-	ID  string `json:"id"`
-	Rev string `json:"rev"`
-	Ok  bool   `json:"ok"`
+	ID     string `json:"id"`
+	Rev    string `json:"rev"`
+	Ok     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // DatabaseChanges represents all changes related to current database
@@ -66,6 +80,21 @@ type DatabaseEvent struct {
 	Deleted bool                `json:"deleted"`
 }
 
+// Sequence is an update sequence of a database. CouchDB 1.x uses integers
+// while 2.x returns opaque strings, so it's kept as is and should only be
+// passed back to the server (e.g. as `since` parameter)
+type Sequence string
+
+// Change represents a single row of the changes feed, unlike DatabaseEvent it
+// keeps the sequence opaque so it can be used with any CouchDB version
+type Change struct {
+	Seq     Sequence            `json:"seq"`
+	ID      string              `json:"id"`
+	Changes []map[string]string `json:"changes"`
+	Deleted bool                `json:"deleted,omitempty"`
+	Doc     json.RawMessage     `json:"doc,omitempty"`
+}
+
 // PurgeResult provides object with result info of purge request
 type PurgeResult struct {
 	PurgeSequence int                 `json:"purge_seq"`
@@ -104,6 +133,106 @@ func queryURL(path ...string) string {
 	return strings.TrimRight(URL, "/")
 }
 
+// Fragmentation returns share of database file not used by live data, it
+// uses `sizes` when server provides them and 1.x fields otherwise
+func (i *DBInfo) Fragmentation() float64 {
+	if i.Sizes.File > 0 {
+		return fragmentation(i.Sizes.Active, i.Sizes.File)
+	}
+	return fragmentation(int64(i.DataSize), int64(i.DiskSize))
+}
+
+// FileSize returns size of database file on disk
+func (i *DBInfo) FileSize() int64 {
+	if i.Sizes.File > 0 {
+		return i.Sizes.File
+	}
+	return int64(i.DiskSize)
+}
+
+func fragmentation(active, file int64) float64 {
+	if file <= 0 || active >= file {
+		return 0
+	}
+	return float64(file-active) / float64(file)
+}
+
+// UnmarshalJSON decodes sequences of any server version
+func (i *DBInfo) UnmarshalJSON(data []byte) error {
+	type dbInfo DBInfo
+	var info struct {
+		dbInfo
+		CommitedUpdateSeq Sequence `json:"committed_update_seq"`
+		PurgeSeq          Sequence `json:"purge_seq"`
+		UpdateSeq         Sequence `json:"update_seq"`
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return err
+	}
+	*i = DBInfo(info.dbInfo)
+	i.committedUpdateSeq, i.purgeSeq, i.updateSeq = info.CommitedUpdateSeq, info.PurgeSeq, info.UpdateSeq
+	i.CommitedUpdateSeq = int(info.CommitedUpdateSeq.Number())
+	i.PurgeSeq = int(info.PurgeSeq.Number())
+	i.UpdateSeq = int(info.UpdateSeq.Number())
+	return nil
+}
+
+// UpdateSequence returns update sequence as reported by the server
+func (i *DBInfo) UpdateSequence() Sequence {
+	return i.updateSeq
+}
+
+// PurgeSequence returns purge sequence as reported by the server
+func (i *DBInfo) PurgeSequence() Sequence {
+	return i.purgeSeq
+}
+
+// CommittedUpdateSequence returns committed update sequence as reported by
+// the server
+func (i *DBInfo) CommittedUpdateSequence() Sequence {
+	return i.committedUpdateSeq
+}
+
+// UnmarshalJSON accepts both numeric and string sequences
+func (s *Sequence) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*s = Sequence(str)
+		return nil
+	}
+	*s = Sequence(data)
+	return nil
+}
+
+// Number returns numeric part of the sequence, for 2.x sequences it's a sum
+// of shard sequences which is enough to compare progress of the same database
+func (s Sequence) Number() int64 {
+	str := string(s)
+	if i := strings.Index(str, "-"); i >= 0 {
+		str = str[:i]
+	}
+	n, _ := strconv.ParseInt(str, 10, 64)
+	return n
+}
+
+// MarshalJSON encodes numeric sequences as numbers and others as strings
+func (s Sequence) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return []byte("null"), nil
+	}
+	if _, err := strconv.ParseInt(string(s), 10, 64); err == nil {
+		return []byte(s), nil
+	}
+	return json.Marshal(string(s))
+}
+
 func (d *Destination) String() (url string) {
 	for k, v := range d.options {
 		url = url + fmt.Sprintf("%s=%v&", k, v)
@@ -124,14 +253,14 @@ func (srv *Server) GetDatabase(name string, auth Auth) (*Database, error) {
 	} else {
 		useAuth = srv.auth
 	}
-	resp, err := srv.conn.request("HEAD", queryURL(name), nil, nil, useAuth, 0)
+	_, err := srv.conn.request("HEAD", queryURL(name), nil, nil, useAuth, 0)
 	if err != nil {
-		if resp.StatusCode == 404 {
+		if isStatus(err, 404) {
 			return nil, errors.New("Not Found")
 		}
 		return nil, err
 	}
-	return &Database{conn: srv.conn, auth: auth, Name: name}, nil
+	return &Database{conn: srv.conn, auth: useAuth, Name: name}, nil
 }
 
 // MustGetDatabase return database instance if it's present on server or creates new one
@@ -145,6 +274,9 @@ func (srv *Server) MustGetDatabase(name string, auth Auth) (*Database, error) {
 		if err != nil {
 			return nil, err
 		}
+		if auth != nil {
+			db = db.WithAuth(auth)
+		}
 		return db, nil
 	}
 	return db, nil
@@ -223,6 +355,9 @@ func (db *Database) Insert(doc interface{}, batch, fullCommit bool) (id, rev str
 	case string:
 		rev = result["rev"].(string)
 	}
+	if !batch {
+		updateIdentifiable(doc, id, rev)
+	}
 	return id, rev, nil
 }
 
@@ -310,6 +445,9 @@ func (db *Database) Update(docs interface{}, atomic, updateRev, fullCommit bool)
 	if err := parseBody(resp, &out); err != nil {
 		return nil, err
 	}
+	if updateRev {
+		updateIdentifiables(docs, out)
+	}
 	return out, nil
 }
 
@@ -349,8 +487,18 @@ func (db *Database) bulkDelete(docs interface{}, atomic, updateRev, fullCommit b
 				m["_deleted"] = true
 				payload = append(payload, m)
 			}
+		} else if implementsIdentifiable(in.Elem()) {
+			v := reflect.ValueOf(docs)
+			for i := 0; i < v.Len(); i++ {
+				doc, _ := identifiableElem(v.Index(i))
+				payload = append(payload, identifiableDeletion(doc))
+			}
 		} else if in.Elem().Kind() == reflect.Interface {
 			for _, tempDoc := range docs.([]interface{}) {
+				if d, ok := tempDoc.(Identifiable); ok {
+					payload = append(payload, identifiableDeletion(d))
+					continue
+				}
 				dt := reflect.TypeOf(tempDoc)
 				dv := reflect.ValueOf(tempDoc)
 				temp := make(map[string]interface{})
@@ -379,6 +527,9 @@ func (db *Database) bulkDelete(docs interface{}, atomic, updateRev, fullCommit b
 		return nil, errors.New("DeleteMany accepts only slices")
 	}
 	result, err = db.Update(payload, atomic, updateRev, fullCommit)
+	if err == nil && updateRev {
+		updateIdentifiables(docs, result)
+	}
 	return
 }
 
@@ -388,7 +539,9 @@ func (db *Database) bulkDelete(docs interface{}, atomic, updateRev, fullCommit b
 // Argument may be `[]interface{}` or `[]map[string]string`
 //
 // If you pass a slice of struct note that they must have fields with
-// tags "_id" and "_rev", or error will be returned
+// tags "_id" and "_rev", or error will be returned. Slices of Identifiable
+// documents or of structs whose pointers are Identifiable are accepted as
+// well, their revisions are updated on success
 // For maps here is a similar requirement, they must have both "_id" and
 // "_rev" keys
 func (db *Database) DeleteMany(docs interface{}) ([]UpdateResult, error) {
@@ -701,6 +854,12 @@ func (db *Database) Get(id string, o interface{}, options Options) error {
 // Note: to add new revision you must specify the latest rev of the document
 // you want to update, otherwise couchdb will answer 409(Conflict)
 func (db *Database) Put(id string, doc interface{}) (string, error) {
+	if d, ok := doc.(Identifiable); ok && id == "" && d.DocID() != "" {
+		id = docPath(d.DocID())
+	}
+	if id == "" {
+		return "", errors.New("Document id is not specified")
+	}
 	headers := map[string]string{"Content-Type": "application/json"}
 	payload, err := json.Marshal(doc)
 	if err != nil {
@@ -716,6 +875,7 @@ func (db *Database) Put(id string, doc interface{}) (string, error) {
 		return "", err
 	}
 	if val, ok := result["ok"]; ok && val.(bool) {
+		updateIdentifiable(doc, "", result["rev"].(string))
 		return result["rev"].(string), nil
 	}
 	return "", err
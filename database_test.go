@@ -1,6 +1,7 @@
 package gocouch
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 	"bytes"
@@ -839,3 +840,60 @@ func TestDatabase_Attachment(t *testing.T) {
 		return
 	}
 }
+
+func TestSequence_UnmarshalJSON(t *testing.T) {
+	var row Change
+	if err := json.Unmarshal([]byte(`{"seq": 12, "id": "doc"}`), &row); err != nil || row.Seq != "12" {
+		t.Logf("Error: %v, seq: %q\n", err, row.Seq)
+		t.Fail()
+		return
+	}
+	if err := json.Unmarshal([]byte(`{"seq": "3-g1AAAA", "id": "doc"}`), &row); err != nil || row.Seq != "3-g1AAAA" {
+		t.Logf("Error: %v, seq: %q\n", err, row.Seq)
+		t.Fail()
+		return
+	}
+	payload, err := json.Marshal(Sequence("12"))
+	if err != nil || string(payload) != "12" {
+		t.Logf("Error: %v, payload: %s\n", err, payload)
+		t.Fail()
+	}
+}
+
+func TestDBInfo_Fragmentation(t *testing.T) {
+	info := DBInfo{DataSize: 25, DiskSize: 100}
+	if info.Fragmentation() != 0.75 || info.FileSize() != 100 {
+		t.Logf("Unexpected fragmentation: %v\n", info.Fragmentation())
+		t.Fail()
+	}
+	info.Sizes = Sizes{Active: 50, File: 200}
+	if info.Fragmentation() != 0.75 || info.FileSize() != 200 {
+		t.Logf("Unexpected fragmentation: %v\n", info.Fragmentation())
+		t.Fail()
+	}
+}
+
+func TestSequence_Number(t *testing.T) {
+	if n := Sequence("42").Number(); n != 42 {
+		t.Logf("Unexpected number: %d\n", n)
+		t.Fail()
+	}
+	if n := Sequence("17-g1AAAAFTeJzLYWBg4MhgTmHgz8tPSTV0MDQy").Number(); n != 17 {
+		t.Logf("Unexpected number: %d\n", n)
+		t.Fail()
+	}
+}
+
+func TestDBInfo_UnmarshalJSON(t *testing.T) {
+	var info DBInfo
+	data := `{"db_name":"db","update_seq":"12-g1AAAA","purge_seq":0,"sizes":{"file":10}}`
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if info.UpdateSeq != 12 || info.UpdateSequence() != "12-g1AAAA" || info.PurgeSequence() != "0" || info.Sizes.File != 10 {
+		t.Logf("Unexpected info: %+v\n", info)
+		t.Fail()
+	}
+}
@@ -0,0 +1,224 @@
+package gocouch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Feed types supported by `_db_updates` and `_changes`
+const (
+	FeedContinuous  = "continuous"
+	FeedLongpoll    = "longpoll"
+	FeedEventSource = "eventsource"
+)
+
+// DBUpdatesOptions describe `_db_updates` subscription
+type DBUpdatesOptions struct {
+	// Feed is one of FeedContinuous (default), FeedLongpoll or FeedEventSource
+	Feed string
+	// Since resumes the feed after given sequence (CouchDB 2.x+)
+	Since Sequence
+	// Heartbeat makes server send empty lines to keep connection alive
+	Heartbeat time.Duration
+	// Timeout is a period server waits for events before closing response
+	Timeout time.Duration
+}
+
+// DBUpdatesFeed follows `_db_updates` of the server until closed. Feed
+// reconnects from the last received sequence whenever server ends response,
+// so it's safe to use with longpoll and timeouts
+type DBUpdatesFeed struct {
+	srv    *Server
+	opts   DBUpdatesOptions
+	conn   *connection
+	events chan ServerEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	seq    Sequence
+	err    error
+}
+
+type dbUpdatesBatch struct {
+	ServerEvent
+	Results []ServerEvent `json:"results"`
+	LastSeq Sequence      `json:"last_seq"`
+}
+
+// DBUpdates opens `_db_updates` feed bound to given context. First request is
+// made synchronously, so errors like missing privileges are returned here
+func (srv *Server) DBUpdates(ctx context.Context, opts DBUpdatesOptions) (*DBUpdatesFeed, error) {
+	if opts.Feed == "" {
+		opts.Feed = FeedContinuous
+	}
+	switch opts.Feed {
+	case FeedContinuous, FeedLongpoll, FeedEventSource:
+	default:
+		return nil, fmt.Errorf("Unsupported feed type: %s", opts.Feed)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f := &DBUpdatesFeed{
+		srv:    srv,
+		opts:   opts,
+		conn:   srv.conn.streaming(),
+		events: make(chan ServerEvent),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		seq:    opts.Since,
+	}
+	resp, err := f.open(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go f.run(ctx, resp)
+	return f, nil
+}
+
+// Events returns channel of server events, it's closed when feed stops
+func (f *DBUpdatesFeed) Events() <-chan ServerEvent {
+	return f.events
+}
+
+// Err returns error that stopped the feed, it's nil while feed is running
+// and after Close
+func (f *DBUpdatesFeed) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Seq returns sequence of the last received event, it may be passed as
+// DBUpdatesOptions.Since to resume the feed later
+func (f *DBUpdatesFeed) Seq() Sequence {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seq
+}
+
+// Close stops the feed and waits until connection is released
+func (f *DBUpdatesFeed) Close() error {
+	f.cancel()
+	<-f.done
+	return nil
+}
+
+func (f *DBUpdatesFeed) open(ctx context.Context) (*http.Response, error) {
+	options := Options{"feed": f.opts.Feed}
+	if seq := f.Seq(); seq != "" {
+		options["since"] = seq
+	}
+	if f.opts.Heartbeat > 0 {
+		options["heartbeat"] = int64(f.opts.Heartbeat / time.Millisecond)
+	}
+	if f.opts.Timeout > 0 {
+		options["timeout"] = int64(f.opts.Timeout / time.Millisecond)
+	}
+	return f.conn.requestContext(ctx, "GET", "/_db_updates"+encodeOptions(options), nil, nil, f.srv.auth)
+}
+
+func (f *DBUpdatesFeed) run(ctx context.Context, resp *http.Response) {
+	defer close(f.done)
+	defer close(f.events)
+	for {
+		err := f.read(ctx, resp)
+		resp.Body.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			resp, err = f.open(ctx)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				f.mu.Lock()
+				f.err = err
+				f.mu.Unlock()
+			}
+			return
+		}
+	}
+}
+
+// read consumes single response of the feed, nil is returned when server
+// ended the response normally
+func (f *DBUpdatesFeed) read(ctx context.Context, resp *http.Response) error {
+	if f.opts.Feed == FeedLongpoll {
+		var batch dbUpdatesBatch
+		if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+			if err == io.EOF {
+				// 1.x answers with empty body on timeout
+				return nil
+			}
+			return err
+		}
+		if batch.Name != "" {
+			batch.Results = append(batch.Results, batch.ServerEvent)
+		}
+		for _, event := range batch.Results {
+			if !f.emit(ctx, event) {
+				return nil
+			}
+		}
+		if batch.LastSeq != "" {
+			f.setSeq(batch.LastSeq)
+		}
+		return nil
+	}
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		line = bytes.TrimSpace(line)
+		if f.opts.Feed == FeedEventSource {
+			if !bytes.HasPrefix(line, []byte("data:")) {
+				// skip event ids, comments and heartbeats
+				continue
+			}
+			line = bytes.TrimSpace(line[len("data:"):])
+		}
+		if len(line) == 0 {
+			continue
+		}
+		var event dbUpdatesBatch
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		if event.LastSeq != "" {
+			f.setSeq(event.LastSeq)
+			return nil
+		}
+		if !f.emit(ctx, event.ServerEvent) {
+			return nil
+		}
+	}
+}
+
+func (f *DBUpdatesFeed) emit(ctx context.Context, event ServerEvent) bool {
+	select {
+	case f.events <- event:
+		if event.Seq != "" {
+			f.setSeq(event.Seq)
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (f *DBUpdatesFeed) setSeq(seq Sequence) {
+	f.mu.Lock()
+	f.seq = seq
+	f.mu.Unlock()
+}
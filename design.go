@@ -0,0 +1,237 @@
+package gocouch
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DesignInfo describes view index of a design document
+type DesignInfo struct {
+	Name      string        `json:"name"`
+	ViewIndex ViewIndexInfo `json:"view_index"`
+}
+
+// ViewIndexInfo contains information about view index of a design document
+type ViewIndexInfo struct {
+	Signature      string   `json:"signature"`
+	Language       string   `json:"language"`
+	CompactRunning bool     `json:"compact_running"`
+	UpdaterRunning bool     `json:"updater_running"`
+	WaitingClients int      `json:"waiting_clients"`
+	WaitingCommit  bool     `json:"waiting_commit"`
+	UpdateSeq      Sequence `json:"update_seq"`
+	PurgeSeq       Sequence `json:"purge_seq"`
+	DataSize       int64    `json:"data_size"`
+	DiskSize       int64    `json:"disk_size"`
+	Sizes          Sizes    `json:"sizes"`
+}
+
+// WarmUpProgress reports state of a design document index during warm-up
+type WarmUpProgress struct {
+	DesignDocument string
+	// IndexSeq is a sequence index is built up to
+	IndexSeq int64
+	// TargetSeq is a database sequence at the beginning of warm-up
+	TargetSeq int64
+	// Progress in percents reported by indexer tasks
+	Progress int
+	Done     bool
+}
+
+// Fragmentation returns share of index file not used by live data
+func (i *ViewIndexInfo) Fragmentation() float64 {
+	if i.Sizes.File > 0 {
+		return fragmentation(i.Sizes.Active, i.Sizes.File)
+	}
+	return fragmentation(i.DataSize, i.DiskSize)
+}
+
+// FileSize returns size of index file on disk
+func (i *ViewIndexInfo) FileSize() int64 {
+	if i.Sizes.File > 0 {
+		return i.Sizes.File
+	}
+	return i.DiskSize
+}
+
+// DesignInfo returns information about view index of the design document,
+// name may be given with or without "_design/" prefix
+func (db *Database) DesignInfo(ddoc string) (*DesignInfo, error) {
+	resp, err := db.conn.request("GET", queryURL(db.Name, "_design", designName(ddoc), "_info"), nil, nil, db.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out DesignInfo
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DesignDocs returns names of all design documents of the database
+// without "_design/" prefix
+func (db *Database) DesignDocs() ([]string, error) {
+	options := Options{"startkey": `"_design/"`, "endkey": `"_design0"`}
+	resp, err := db.conn.request("GET", queryURL(db.Name, "_all_docs")+encodeOptions(options), nil, nil, db.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var result ViewResult
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		if id, ok := row["id"].(string); ok {
+			names = append(names, designName(id))
+		}
+	}
+	return names, nil
+}
+
+func designName(ddoc string) string {
+	return strings.TrimPrefix(ddoc, "_design/")
+}
+
+// WarmUp builds indexes of given design documents (all of them when none
+// given) by querying every view with `limit=0`, and waits until each index
+// is caught up with the database sequence taken at the beginning. Progress
+// is reported to the callback, which may be nil
+func (db *Database) WarmUp(ctx context.Context, ddocs []string, interval time.Duration,
+	progress func(WarmUpProgress)) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if len(ddocs) == 0 {
+		var err error
+		if ddocs, err = db.DesignDocs(); err != nil {
+			return err
+		}
+	}
+	info, err := db.Info()
+	if err != nil {
+		return err
+	}
+	target := info.UpdateSequence().Number()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		queryErr error
+	)
+	conn := db.conn.streaming()
+	pending := make(map[string]bool)
+	for _, ddoc := range ddocs {
+		views, err := db.designViews(ddoc)
+		if err != nil {
+			return err
+		}
+		if len(views) == 0 {
+			continue
+		}
+		pending[designName(ddoc)] = true
+		go func(ddoc string) {
+			for _, view := range views {
+				resp, err := conn.requestContext(ctx, "GET", queryURL(db.Name, "_design", designName(ddoc),
+					"_view", view)+encodeOptions(Options{"limit": 0}), nil, nil, db.auth)
+				if err == nil {
+					err = resp.Body.Close()
+				}
+				if err != nil && ctx.Err() == nil {
+					mu.Lock()
+					queryErr = err
+					mu.Unlock()
+					cancel()
+					return
+				}
+			}
+		}(ddoc)
+	}
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			err := queryErr
+			mu.Unlock()
+			if err != nil {
+				return err
+			}
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		tasks, err := db.activeIndexers()
+		if err != nil {
+			return err
+		}
+		for ddoc := range pending {
+			info, err := db.DesignInfo(ddoc)
+			if err != nil {
+				return err
+			}
+			state := WarmUpProgress{
+				DesignDocument: ddoc,
+				IndexSeq:       info.ViewIndex.UpdateSeq.Number(),
+				TargetSeq:      target,
+				Progress:       tasks[ddoc],
+			}
+			if state.IndexSeq >= target {
+				state.Done, state.Progress = true, 100
+				delete(pending, ddoc)
+			}
+			if progress != nil {
+				progress(state)
+			}
+		}
+	}
+	return nil
+}
+
+// designViews returns names of map/reduce views defined in the design document
+func (db *Database) designViews(ddoc string) ([]string, error) {
+	var doc struct {
+		Language string                     `json:"language"`
+		Views    map[string]json.RawMessage `json:"views"`
+	}
+	if err := db.Get("_design/"+designName(ddoc), &doc, nil); err != nil {
+		return nil, err
+	}
+	if doc.Language == "query" {
+		// mango indexes can't be queried as views
+		return nil, nil
+	}
+	views := make([]string, 0, len(doc.Views))
+	for name := range doc.Views {
+		views = append(views, name)
+	}
+	return views, nil
+}
+
+// activeIndexers returns average progress of indexer tasks of the database
+// per design document
+func (db *Database) activeIndexers() (map[string]int, error) {
+	srv := &Server{auth: db.auth, conn: db.conn}
+	tasks, err := srv.ActiveTasks()
+	if isStatus(err, 401) || isStatus(err, 403) {
+		// active tasks are available to admins only, progress is unknown
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sum, count := make(map[string]int), make(map[string]int)
+	for _, task := range tasks {
+		indexer, ok := task.(*IndexerTask)
+		if !ok || !matchDatabase(indexer.Database, db.Name) {
+			continue
+		}
+		ddoc := designName(indexer.DesignDocument)
+		sum[ddoc] += indexer.Progress
+		count[ddoc]++
+	}
+	for ddoc := range sum {
+		sum[ddoc] /= count[ddoc]
+	}
+	return sum, nil
+}
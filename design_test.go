@@ -0,0 +1,71 @@
+package gocouch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDatabase_DesignInfo(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("design_info", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	_, err = db.Put("_design/test", map[string]interface{}{
+		"views": map[string]interface{}{
+			"all": map[string]string{"map": "function(doc) { emit(doc._id, null); }"},
+		},
+	})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	ddocs, err := db.DesignDocs()
+	if err != nil || len(ddocs) != 1 || ddocs[0] != "test" {
+		t.Logf("Error: %v, design docs: %v\n", err, ddocs)
+		t.Fail()
+		return
+	}
+	info, err := db.DesignInfo("_design/test")
+	if err != nil || info.Name != "test" {
+		t.Logf("Error: %v, info: %#v\n", err, info)
+		t.Fail()
+	}
+}
+
+func TestDatabase_WarmUp(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("design_warm_up", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	for i := 0; i < 100; i++ {
+		db.Insert(map[string]int{"value": i}, false, false)
+	}
+	_, err = db.Put("_design/warm", map[string]interface{}{
+		"views": map[string]interface{}{
+			"values": map[string]string{"map": "function(doc) { emit(doc.value, null); }"},
+		},
+	})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var last WarmUpProgress
+	err = db.WarmUp(ctx, nil, 100*time.Millisecond, func(p WarmUpProgress) { last = p })
+	if err != nil || !last.Done || last.DesignDocument != "warm" {
+		t.Logf("Error: %v, progress: %#v\n", err, last)
+		t.Fail()
+	}
+}
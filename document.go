@@ -0,0 +1,118 @@
+package gocouch
+
+import "reflect"
+
+// Identifiable is implemented by documents which allow the library to read
+// and update their id and revision, e.g. pointers to structs embedding
+// Document. Insert, Put, Update, InsertMany and DeleteMany set new revision
+// of such documents on success
+type Identifiable interface {
+	DocID() string
+	DocRev() string
+	SetDocID(id string)
+	SetDocRev(rev string)
+}
+
+// Document contains special fields of a CouchDB document, embed it into
+// document structs
+type Document struct {
+	ID          string                    `json:"_id,omitempty"`
+	Rev         string                    `json:"_rev,omitempty"`
+	Deleted     bool                      `json:"_deleted,omitempty"`
+	Attachments map[string]AttachmentStub `json:"_attachments,omitempty"`
+	Conflicts   []string                  `json:"_conflicts,omitempty"`
+	Revisions   *Revisions                `json:"_revisions,omitempty"`
+}
+
+// AttachmentStub describes attachment inlined into a document, Data is
+// present only for attachments requested with `attachments=true` or added
+// inline, otherwise Stub is set
+type AttachmentStub struct {
+	ContentType   string `json:"content_type"`
+	Data          []byte `json:"data,omitempty"`
+	Digest        string `json:"digest,omitempty"`
+	EncodedLength int64  `json:"encoded_length,omitempty"`
+	Encoding      string `json:"encoding,omitempty"`
+	Length        int64  `json:"length,omitempty"`
+	RevPos        int    `json:"revpos,omitempty"`
+	Stub          bool   `json:"stub,omitempty"`
+	Follows       bool   `json:"follows,omitempty"`
+}
+
+// Revisions is a revision history of a document requested with `revs=true`,
+// IDs are revision hashes starting from the latest one
+type Revisions struct {
+	Start int      `json:"start"`
+	IDs   []string `json:"ids"`
+}
+
+// DocID returns document id
+func (d *Document) DocID() string {
+	return d.ID
+}
+
+// DocRev returns document revision
+func (d *Document) DocRev() string {
+	return d.Rev
+}
+
+// SetDocID sets document id
+func (d *Document) SetDocID(id string) {
+	d.ID = id
+}
+
+// SetDocRev sets document revision
+func (d *Document) SetDocRev(rev string) {
+	d.Rev = rev
+}
+
+var identifiableType = reflect.TypeOf((*Identifiable)(nil)).Elem()
+
+// identifiableDeletion returns deletion stub of Identifiable document
+func identifiableDeletion(doc Identifiable) map[string]interface{} {
+	return map[string]interface{}{"_id": doc.DocID(), "_rev": doc.DocRev(), "_deleted": true}
+}
+
+// updateIdentifiable sets id and revision of Identifiable document
+func updateIdentifiable(doc interface{}, id, rev string) {
+	if d, ok := doc.(Identifiable); ok {
+		if id != "" {
+			d.SetDocID(id)
+		}
+		d.SetDocRev(rev)
+	}
+}
+
+// updateIdentifiables sets revisions of Identifiable documents of a slice
+// from bulk results, which follow the order of documents
+func updateIdentifiables(docs interface{}, results []UpdateResult) {
+	v := reflect.ValueOf(docs)
+	if v.Kind() != reflect.Slice {
+		return
+	}
+	for i := 0; i < v.Len() && i < len(results); i++ {
+		if results[i].Error == "" && results[i].Rev != "" {
+			if doc, ok := identifiableElem(v.Index(i)); ok {
+				updateIdentifiable(doc, results[i].ID, results[i].Rev)
+			}
+		}
+	}
+}
+
+// implementsIdentifiable reports whether slice elements of type t are
+// Identifiable themselves or through their address
+func implementsIdentifiable(t reflect.Type) bool {
+	return t.Implements(identifiableType) || reflect.PtrTo(t).Implements(identifiableType)
+}
+
+// identifiableElem returns slice element as Identifiable, value elements
+// are taken by address so that their updates are kept in the slice
+func identifiableElem(v reflect.Value) (Identifiable, bool) {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		if doc, ok := v.Addr().Interface().(Identifiable); ok {
+			return doc, true
+		}
+	}
+	doc, ok := v.Interface().(Identifiable)
+	return doc, ok
+}
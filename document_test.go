@@ -0,0 +1,102 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type testDocument struct {
+	Document
+	Name string `json:"name"`
+}
+
+func TestDocument_UnmarshalJSON(t *testing.T) {
+	data := `{"_id":"doc","_rev":"2-b","_conflicts":["2-a"],"name":"milk",
+		"_revisions":{"start":2,"ids":["b","a"]},
+		"_attachments":{"note.txt":{"content_type":"text/plain","digest":"md5-x","length":5,"revpos":1,"stub":true}}}`
+	var doc testDocument
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if doc.ID != "doc" || doc.Rev != "2-b" || doc.Name != "milk" || len(doc.Conflicts) != 1 {
+		t.Logf("Unexpected document: %+v\n", doc)
+		t.Fail()
+	}
+	if doc.Revisions == nil || doc.Revisions.Start != 2 || !doc.Attachments["note.txt"].Stub {
+		t.Logf("Unexpected metadata: %+v\n", doc.Document)
+		t.Fail()
+	}
+	out, err := json.Marshal(testDocument{Name: "new"})
+	if err != nil || string(out) != `{"name":"new"}` {
+		t.Logf("Unexpected encoding: %s, error: %v\n", out, err)
+		t.Fail()
+	}
+}
+
+func TestDatabase_PutWithoutID(t *testing.T) {
+	db := &Database{Name: "db"}
+	if _, err := db.Put("", &testDocument{Name: "no id"}); err == nil {
+		t.Log("Document without id is accepted")
+		t.Fail()
+	}
+}
+
+func TestUpdateIdentifiables(t *testing.T) {
+	docs := []*testDocument{{Name: "a"}, {Name: "b"}}
+	updateIdentifiables(docs, []UpdateResult{{ID: "a", Rev: "1-a", Ok: true}, {ID: "b", Error: "conflict"}})
+	if docs[0].ID != "a" || docs[0].Rev != "1-a" || docs[1].Rev != "" {
+		t.Logf("Unexpected documents: %+v %+v\n", docs[0], docs[1])
+		t.Fail()
+	}
+	values := []testDocument{{Name: "a"}}
+	updateIdentifiables(values, []UpdateResult{{ID: "a", Rev: "1-a", Ok: true}})
+	if values[0].ID != "a" || values[0].Rev != "1-a" {
+		t.Logf("Unexpected document: %+v\n", values[0])
+		t.Fail()
+	}
+}
+
+func TestDatabase_Identifiable(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("identifiable_test", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	doc := &testDocument{Name: "inserted"}
+	if _, _, err := db.Insert(doc, false, false); err != nil || doc.ID == "" || doc.Rev == "" {
+		t.Logf("Error: %v, doc: %+v\n", err, doc)
+		t.Fail()
+		return
+	}
+	doc.Name = "updated"
+	if _, err := db.Put("", doc); err != nil || doc.Rev[:2] != "2-" {
+		t.Logf("Error: %v, doc: %+v\n", err, doc)
+		t.Fail()
+		return
+	}
+	docs := []*testDocument{doc, {Document: Document{ID: "second"}}}
+	if _, err := db.InsertMany(docs); err != nil || doc.Rev[:2] != "3-" || docs[1].Rev == "" {
+		t.Logf("Error: %v, docs: %+v %+v\n", err, docs[0], docs[1])
+		t.Fail()
+		return
+	}
+	if _, err := db.DeleteMany(docs); err != nil || docs[1].Rev[:2] != "2-" {
+		t.Logf("Error: %v, docs: %+v %+v\n", err, docs[0], docs[1])
+		t.Fail()
+	}
+	values := []testDocument{{Name: "first"}, {Document: Document{ID: "third"}}}
+	if _, err := db.InsertMany(values); err != nil || values[0].Rev == "" || values[1].Rev == "" {
+		t.Logf("Error: %v, docs: %+v %+v\n", err, values[0], values[1])
+		t.Fail()
+		return
+	}
+	if _, err := db.DeleteMany(values); err != nil || values[1].Rev[:2] != "2-" {
+		t.Logf("Error: %v, docs: %+v %+v\n", err, values[0], values[1])
+		t.Fail()
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/pupizoid/gocouch
+
+go 1.18
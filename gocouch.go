@@ -3,6 +3,7 @@ package gocouch
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -43,31 +44,100 @@ func createConnection(dest string, timeout time.Duration) (*connection, error) {
 	return &connection{validatedURL.String(), &http.Client{Timeout: timeout}}, nil
 }
 
+// streaming returns a copy of connection sharing the same transport but without
+// overall request timeout, it's used by long living feeds that are stopped by
+// context cancellation instead
+func (conn *connection) streaming() *connection {
+	return &connection{conn.url, &http.Client{Transport: conn.client.Transport, Jar: conn.client.Jar}}
+}
+
 func (conn *connection) request(method, path string,
 	headers map[string]string, body io.Reader, auth Auth, timeout time.Duration) (*http.Response, error) {
+	return conn.requestContext(context.Background(), method, path, headers, body, auth)
+}
 
-	req, err := http.NewRequest(method, conn.url+path, body)
-	for k, v := range headers {
-		req.Header.Set(k, v)
+// requestContext acts like request but binds it to the given context
+func (conn *connection) requestContext(ctx context.Context, method, path string,
+	headers map[string]string, body io.Reader, auth Auth) (*http.Response, error) {
+
+	req, err := conn.newRequest(ctx, method, path, headers, body)
+	if err != nil {
+		return nil, err
 	}
+	resp, err := conn.send(req, auth)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode >= 400 {
+		return resp, parseError(resp)
+	}
+	return resp, nil
+}
+
+// send authorizes request with auth and sends it, response status is left
+// to the caller
+func (conn *connection) send(req *http.Request, auth Auth) (*http.Response, error) {
+	if r, ok := auth.(renewer); ok {
+		return conn.renewingDo(req, r, true)
+	}
 	if auth != nil {
 		auth.AddAuthHeaders(req)
 	}
-	return conn.processResponse(req)
+	return conn.client.Do(req)
+}
+
+func (conn *connection) newRequest(ctx context.Context, method, path string,
+	headers map[string]string, body io.Reader) (*http.Request, error) {
+
+	req, err := http.NewRequest(method, conn.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
 }
 
-func (conn *connection) processResponse(req *http.Request) (*http.Response, error) {
+// renewingDo sends request authorized by renewer and replays it once when
+// renewer refreshed credentials rejected by the server, requests with body
+// are replayed only if the body can be read again
+func (conn *connection) renewingDo(req *http.Request, r renewer, replay bool) (*http.Response, error) {
+	headers := make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		headers[k] = append([]string(nil), v...)
+	}
+	if err := r.authorize(conn, req); err != nil {
+		return nil, err
+	}
 	resp, err := conn.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode >= 400 {
-		return resp, parseError(resp)
+	replay = replay && (req.Body == nil || req.GetBody != nil)
+	retry, err := r.handleResponse(conn, req, resp, replay)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
 	}
-	return resp, nil
+	if !retry {
+		return resp, nil
+	}
+	resp.Body.Close()
+	var body io.Reader
+	if req.GetBody != nil {
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	next, err := http.NewRequest(req.Method, req.URL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	next = next.WithContext(req.Context())
+	next.Header = headers
+	return conn.renewingDo(next, r, false)
 }
 
 //stringify the error
@@ -94,6 +164,43 @@ func parseError(resp *http.Response) error {
 	}
 }
 
+// isStatus reports whether err is a CouchDB error with given status code
+func isStatus(err error, code int) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == code
+}
+
+// bulkError converts error of a document in bulk request to Error, status
+// code is derived from the error name
+func bulkError(code, reason string) error {
+	statuses := map[string]int{
+		"bad_request":  http.StatusBadRequest,
+		"unauthorized": http.StatusUnauthorized,
+		"forbidden":    http.StatusForbidden,
+		"not_found":    http.StatusNotFound,
+		"conflict":     http.StatusConflict,
+	}
+	status, ok := statuses[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	return &Error{StatusCode: status, ErrorCode: code, Reason: reason}
+}
+
+// encodeOptions builds an escaped query string from options, values are
+// formatted the same way as in other methods, result is prefixed with "?"
+// unless options are empty
+func encodeOptions(options Options) string {
+	if len(options) == 0 {
+		return ""
+	}
+	values := url.Values{}
+	for k, v := range options {
+		values.Set(k, fmt.Sprint(v))
+	}
+	return "?" + values.Encode()
+}
+
 //unmarshalls a JSON Response Body
 func parseBody(resp *http.Response, o interface{}) error {
 	err := json.NewDecoder(resp.Body).Decode(o)
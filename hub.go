@@ -0,0 +1,352 @@
+package gocouch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SlowConsumerPolicy defines what hub does when subscriber's buffer is full
+type SlowConsumerPolicy int
+
+const (
+	// BlockSlowConsumer makes hub wait until subscriber reads the event,
+	// which delays delivery to all subscribers of the same database
+	BlockSlowConsumer SlowConsumerPolicy = iota
+	// DropForSlowConsumer skips events that don't fit into subscriber's buffer
+	DropForSlowConsumer
+	// DisconnectSlowConsumer closes subscription with ErrSlowConsumer
+	DisconnectSlowConsumer
+)
+
+// ErrSlowConsumer is reported by subscription disconnected due to full buffer
+var ErrSlowConsumer = errors.New("Subscriber can't keep up with the changes feed")
+
+// ErrHubClosed is reported by subscriptions closed together with the hub
+var ErrHubClosed = errors.New("Changes hub closed")
+
+// ChangeFilter decides whether an event should be delivered to subscriber
+type ChangeFilter func(Change) bool
+
+// SubscribeOptions describe subscription to the ChangesHub
+type SubscribeOptions struct {
+	// Filter selects events for subscriber, nil means all events
+	Filter ChangeFilter
+	// Buffer is a capacity of subscriber's channel
+	Buffer int
+	// Policy applied when the buffer is full
+	Policy SlowConsumerPolicy
+}
+
+// ChangesHub holds a single continuous changes feed per database and fans out
+// its events to any number of in-process subscribers. Upstream feed is opened
+// with the first subscription and closed after the last one is gone
+type ChangesHub struct {
+	options Options
+	mu      sync.Mutex
+	feeds   map[string]*hubFeed
+	closed  bool
+}
+
+// Subscription receives events of a single database from the ChangesHub
+type Subscription struct {
+	feed      *hubFeed
+	filter    ChangeFilter
+	policy    SlowConsumerPolicy
+	events    chan Change
+	done      chan struct{}
+	closeOnce sync.Once
+	// sendMu is held while event is sent, so events is never closed under
+	// a blocked sender
+	sendMu  sync.Mutex
+	dropped uint64
+	err     error
+}
+
+type hubFeed struct {
+	hub    *ChangesHub
+	key    string
+	db     *Database
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// IDPrefix returns filter selecting documents whose id starts with prefix
+func IDPrefix(prefix string) ChangeFilter {
+	return func(c Change) bool {
+		return strings.HasPrefix(c.ID, prefix)
+	}
+}
+
+// NewChangesHub creates hub, given options are added to every upstream
+// `_changes` request (e.g. include_docs or filter)
+func NewChangesHub(options Options) *ChangesHub {
+	return &ChangesHub{options: options, feeds: make(map[string]*hubFeed)}
+}
+
+// Subscribe registers new subscriber to changes of the database, events
+// happened before subscription are not delivered
+func (h *ChangesHub) Subscribe(db *Database, opts SubscribeOptions) (*Subscription, error) {
+	sub := &Subscription{
+		filter: opts.Filter,
+		policy: opts.Policy,
+		events: make(chan Change, opts.Buffer),
+		done:   make(chan struct{}),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	// events are fetched with credentials of the first subscriber, so
+	// feeds are shared only between subscribers with the same auth
+	key := db.conn.url + queryURL(db.Name) + "\x00" + authKey(db.auth)
+	feed, ok := h.feeds[key]
+	if ok {
+		feed.mu.Lock()
+		if !feed.closed {
+			sub.feed = feed
+			feed.subs[sub] = struct{}{}
+		}
+		feed.mu.Unlock()
+	}
+	if sub.feed == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		feed = &hubFeed{hub: h, key: key, db: db, cancel: cancel, subs: make(map[*Subscription]struct{})}
+		sub.feed = feed
+		feed.subs[sub] = struct{}{}
+		h.feeds[key] = feed
+		go feed.run(ctx)
+	}
+	return sub, nil
+}
+
+// Close stops all upstream feeds and closes every subscription
+func (h *ChangesHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, feed := range h.feeds {
+		feed.mu.Lock()
+		for sub := range feed.subs {
+			feed.removeLocked(sub, ErrHubClosed)
+		}
+		feed.mu.Unlock()
+	}
+}
+
+// Events returns channel of subscribed events, it's closed when
+// subscription ends
+func (s *Subscription) Events() <-chan Change {
+	return s.events
+}
+
+// Err returns the reason subscription was closed by the hub, it's nil
+// while subscription is active or if it was closed by the subscriber
+func (s *Subscription) Err() error {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	return s.err
+}
+
+// Dropped returns count of events skipped due to full buffer
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close unsubscribes from the hub and closes events channel
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.feed.mu.Lock()
+	s.feed.removeLocked(s, nil)
+	s.feed.mu.Unlock()
+}
+
+// removeLocked detaches subscription from the feed and stops the upstream
+// when nobody is listening anymore. feed.mu must be held
+func (f *hubFeed) removeLocked(sub *Subscription, reason error) {
+	if _, ok := f.subs[sub]; !ok {
+		return
+	}
+	sub.closeOnce.Do(func() { close(sub.done) })
+	delete(f.subs, sub)
+	sub.err = reason
+	sub.sendMu.Lock()
+	close(sub.events)
+	sub.sendMu.Unlock()
+	if len(f.subs) == 0 {
+		f.closed = true
+		f.cancel()
+	}
+}
+
+// authKey identifies credentials of auth, pointers are compared by identity
+func authKey(auth Auth) string {
+	if auth == nil {
+		return ""
+	}
+	if reflect.ValueOf(auth).Kind() == reflect.Ptr {
+		return fmt.Sprintf("%T@%p", auth, auth)
+	}
+	return fmt.Sprintf("%#v", auth)
+}
+
+// dispatch passes event to every matching subscriber according to its
+// policy, feed lock is not held while waiting for slow subscribers
+func (f *hubFeed) dispatch(ctx context.Context, event Change) {
+	f.mu.Lock()
+	subs := make([]*Subscription, 0, len(f.subs))
+	for sub := range f.subs {
+		subs = append(subs, sub)
+	}
+	f.mu.Unlock()
+	for _, sub := range subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		if !sub.send(ctx, event) {
+			f.mu.Lock()
+			f.removeLocked(sub, ErrSlowConsumer)
+			f.mu.Unlock()
+		}
+	}
+}
+
+// send delivers event according to subscription policy, false is returned
+// when subscriber has to be disconnected
+func (s *Subscription) send(ctx context.Context, event Change) bool {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	select {
+	case <-s.done:
+		return true
+	default:
+	}
+	switch s.policy {
+	case BlockSlowConsumer:
+		select {
+		case s.events <- event:
+		case <-s.done:
+		case <-ctx.Done():
+		}
+	case DropForSlowConsumer:
+		select {
+		case s.events <- event:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case DisconnectSlowConsumer:
+		select {
+		case s.events <- event:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// run keeps upstream feed open, reconnecting from the last seen sequence
+// until the feed is cancelled
+func (f *hubFeed) run(ctx context.Context) {
+	defer func() {
+		f.hub.mu.Lock()
+		if f.hub.feeds[f.key] == f {
+			delete(f.hub.feeds, f.key)
+		}
+		f.hub.mu.Unlock()
+	}()
+	conn := f.db.conn.streaming()
+	// concrete sequence is resolved on the first connection, so reconnects
+	// don't skip changes made while the feed was down
+	var since Sequence
+	delay := time.Second
+	for {
+		if err := f.follow(ctx, conn, &since); err == nil {
+			delay = time.Second
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+// follow reads continuous feed line by line until it ends or fails
+func (f *hubFeed) follow(ctx context.Context, conn *connection, since *Sequence) error {
+	if *since == "" {
+		seq, err := f.currentSeq(ctx, conn)
+		if err != nil {
+			return err
+		}
+		*since = seq
+	}
+	options := Options{}
+	for k, v := range f.hub.options {
+		options[k] = v
+	}
+	options["feed"] = continuous
+	options["heartbeat"] = 10000
+	options["since"] = *since
+	resp, err := conn.requestContext(ctx, "GET",
+		queryURL(f.db.Name, "_changes")+encodeOptions(options), nil, nil, f.db.auth)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			// heartbeat
+			continue
+		}
+		var event struct {
+			Change
+			LastSeq Sequence `json:"last_seq"`
+		}
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		if event.LastSeq != "" {
+			*since = event.LastSeq
+			return nil
+		}
+		f.dispatch(ctx, event.Change)
+		*since = event.Seq
+	}
+}
+
+// currentSeq returns update sequence of the database
+func (f *hubFeed) currentSeq(ctx context.Context, conn *connection) (Sequence, error) {
+	resp, err := conn.requestContext(ctx, "GET", queryURL(f.db.Name), nil, nil, f.db.auth)
+	if err != nil {
+		return "", err
+	}
+	var info DBInfo
+	if err := parseBody(resp, &info); err != nil {
+		return "", err
+	}
+	return info.UpdateSequence(), nil
+}
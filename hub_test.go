@@ -0,0 +1,220 @@
+package gocouch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChangesHub_Subscribe(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("changes_hub", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	hub := NewChangesHub(nil)
+	defer hub.Close()
+	all, err := hub.Subscribe(db, SubscribeOptions{Buffer: 10})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	users, err := hub.Subscribe(db, SubscribeOptions{Filter: IDPrefix("user:"), Buffer: 10})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	// let upstream feed connect
+	time.Sleep(time.Second)
+	db.Put("order:1", map[string]string{})
+	db.Put("user:1", map[string]string{})
+	for _, id := range []string{"order:1", "user:1"} {
+		select {
+		case event := <-all.Events():
+			if event.ID != id {
+				t.Logf("Unexpected event: %#v\n", event)
+				t.Fail()
+			}
+		case <-time.After(5 * time.Second):
+			t.Log("Timeout waiting for event")
+			t.Fail()
+			return
+		}
+	}
+	select {
+	case event := <-users.Events():
+		if event.ID != "user:1" {
+			t.Logf("Unexpected event: %#v\n", event)
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("Timeout waiting for filtered event")
+		t.Fail()
+	}
+	users.Close()
+	if _, ok := <-users.Events(); ok || users.Err() != nil {
+		t.Logf("Subscription is not closed cleanly: %v\n", users.Err())
+		t.Fail()
+	}
+}
+
+func TestChangesHub_SlowConsumer(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("changes_hub_slow", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	hub := NewChangesHub(nil)
+	defer hub.Close()
+	sub, err := hub.Subscribe(db, SubscribeOptions{Buffer: 1, Policy: DisconnectSlowConsumer})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	time.Sleep(time.Second)
+	for i := 0; i < 3; i++ {
+		db.Insert(map[string]int{"value": i}, false, false)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-sub.Events():
+			if !ok {
+				if sub.Err() != ErrSlowConsumer {
+					t.Logf("Unexpected error: %v\n", sub.Err())
+					t.Fail()
+				}
+				return
+			}
+			// don't read further to overflow the buffer
+			time.Sleep(time.Second)
+		case <-timeout:
+			t.Log("Slow subscriber was not disconnected")
+			t.Fail()
+			return
+		}
+	}
+}
+
+func changesServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/db" {
+			w.Write([]byte(`{"db_name":"db","update_seq":"0"}`))
+			return
+		}
+		for i := 1; ; i++ {
+			if _, err := fmt.Fprintf(w, "{\"seq\":\"%d\",\"id\":\"doc%d\",\"changes\":[]}\n", i, i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+}
+
+func TestChangesHub_CloseBlockedSubscriber(t *testing.T) {
+	ts := changesServer()
+	defer ts.Close()
+	conn, _ := createConnection(ts.URL, 0)
+	db := &Database{conn: conn, Name: "db"}
+	hub := NewChangesHub(nil)
+	sub, err := hub.Subscribe(db, SubscribeOptions{})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	// dispatcher is blocked on unbuffered subscriber
+	time.Sleep(100 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		sub.Err()
+		hub.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Log("Hub close is blocked by slow subscriber")
+		t.Fail()
+		return
+	}
+	for range sub.Events() {
+	}
+	if sub.Err() != ErrHubClosed {
+		t.Logf("Unexpected error: %v\n", sub.Err())
+		t.Fail()
+	}
+}
+
+func TestChangesHub_SubscribeAuth(t *testing.T) {
+	ts := changesServer()
+	defer ts.Close()
+	conn, _ := createConnection(ts.URL, 0)
+	hub := NewChangesHub(nil)
+	defer hub.Close()
+	db := &Database{conn: conn, Name: "db", auth: BasicAuth{"admin", "admin"}}
+	for _, view := range []*Database{db, db.WithAuth(BasicAuth{"admin", "admin"}), db.WithAuth(BasicAuth{"user", "user"})} {
+		if _, err := hub.Subscribe(view, SubscribeOptions{Policy: DropForSlowConsumer}); err != nil {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+			return
+		}
+	}
+	hub.mu.Lock()
+	feeds := len(hub.feeds)
+	hub.mu.Unlock()
+	if feeds != 2 {
+		t.Logf("Expected feed per credentials, got %d feeds\n", feeds)
+		t.Fail()
+	}
+}
+
+func TestChangesHub_ResumeFromSequence(t *testing.T) {
+	sinces := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/db" {
+			w.Write([]byte(`{"db_name":"db","update_seq":"5-g1AAAA"}`))
+			return
+		}
+		// connection drops before any event
+		sinces <- r.URL.Query().Get("since")
+	}))
+	defer ts.Close()
+	conn, _ := createConnection(ts.URL, 0)
+	hub := NewChangesHub(nil)
+	defer hub.Close()
+	if _, err := hub.Subscribe(&Database{conn: conn, Name: "db"}, SubscribeOptions{}); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case since := <-sinces:
+			if since != "5-g1AAAA" {
+				t.Logf("Unexpected since: %v\n", since)
+				t.Fail()
+			}
+		case <-time.After(5 * time.Second):
+			t.Log("Timeout waiting for reconnect")
+			t.Fail()
+			return
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package gocouch
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWT signing algorithms supported by JWTSigner
+const (
+	JWTHS256 = "HS256"
+	JWTRS256 = "RS256"
+	JWTES256 = "ES256"
+)
+
+// JWT errors
+var (
+	ErrUnsupportedAlgorithm = errors.New("Unsupported JWT signing algorithm")
+	ErrInvalidKey           = errors.New("Key does not match JWT signing algorithm")
+	ErrEmptyToken           = errors.New("Token source returned empty token")
+)
+
+// Token is a bearer token, zero Expiry means token never expires
+type Token struct {
+	Value  string
+	Expiry time.Time
+}
+
+// TokenSource provides tokens to JWTAuth
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenSourceFunc allows to use ordinary function as TokenSource
+type TokenSourceFunc func() (*Token, error)
+
+// Token calls f()
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// JWTAuth authenticates requests with bearer token (CouchDB 3.x). Token is
+// requested from the Source on the first request, RefreshBefore its expiry
+// and when the server rejects it. It's safe for concurrent use
+type JWTAuth struct {
+	Source        TokenSource
+	RefreshBefore time.Duration
+	mu            sync.Mutex
+	token         *Token
+}
+
+// NewJWTAuth returns bearer token authentication refreshing tokens a minute
+// before they expire
+func NewJWTAuth(source TokenSource) *JWTAuth {
+	return &JWTAuth{Source: source, RefreshBefore: time.Minute}
+}
+
+// AddAuthHeaders adds current token to the request if there is one
+func (a *JWTAuth) AddAuthHeaders(req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != nil {
+		req.Header.Set("Authorization", "Bearer "+a.token.Value)
+	}
+}
+
+func (a *JWTAuth) authorize(conn *connection, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == nil || (!a.token.Expiry.IsZero() && time.Now().Add(a.RefreshBefore).After(a.token.Expiry)) {
+		if err := a.refresh(); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token.Value)
+	return nil
+}
+
+func (a *JWTAuth) handleResponse(conn *connection, req *http.Request, resp *http.Response, replay bool) (bool, error) {
+	if resp.StatusCode != http.StatusUnauthorized || !replay {
+		return false, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// other request may have already refreshed the token
+	if a.token != nil && req.Header.Get("Authorization") != "Bearer "+a.token.Value {
+		return true, nil
+	}
+	if err := a.refresh(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// refresh requests new token, caller must hold the lock
+func (a *JWTAuth) refresh() error {
+	token, err := a.Source.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil || token.Value == "" {
+		return ErrEmptyToken
+	}
+	a.token = token
+	return nil
+}
+
+// JWTSigner issues tokens signed locally, it's meant for service accounts.
+// Key is a []byte secret for HS256, *rsa.PrivateKey for RS256 and
+// *ecdsa.PrivateKey with P-256 curve for ES256
+type JWTSigner struct {
+	Algorithm string
+	Key       interface{}
+	// KeyID is put to `kid` header, it selects the key configured in
+	// `jwt_keys` section of the server
+	KeyID   string
+	Subject string
+	// Roles are put to `_couchdb.roles` claim
+	Roles    []string
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// Claims are added to the token as is
+	Claims map[string]interface{}
+}
+
+// Token issues a new token valid for TTL (an hour by default)
+func (s *JWTSigner) Token() (*Token, error) {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	now := time.Now()
+	alg := strings.ToUpper(s.Algorithm)
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if s.KeyID != "" {
+		header["kid"] = s.KeyID
+	}
+	claims := map[string]interface{}{}
+	for k, v := range s.Claims {
+		claims[k] = v
+	}
+	claims["sub"] = s.Subject
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	if s.Roles != nil {
+		claims["_couchdb.roles"] = s.Roles
+	}
+	if s.Issuer != "" {
+		claims["iss"] = s.Issuer
+	}
+	if s.Audience != "" {
+		claims["aud"] = s.Audience
+	}
+	encodedHeader, err := encodeJWTPart(header)
+	if err != nil {
+		return nil, err
+	}
+	encodedClaims, err := encodeJWTPart(claims)
+	if err != nil {
+		return nil, err
+	}
+	payload := encodedHeader + "." + encodedClaims
+	signature, err := s.sign(alg, []byte(payload))
+	if err != nil {
+		return nil, err
+	}
+	return &Token{
+		Value:  payload + "." + base64.RawURLEncoding.EncodeToString(signature),
+		Expiry: now.Add(ttl),
+	}, nil
+}
+
+func (s *JWTSigner) sign(alg string, payload []byte) ([]byte, error) {
+	digest := sha256.Sum256(payload)
+	switch alg {
+	case JWTHS256:
+		key, ok := s.Key.([]byte)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(payload)
+		return mac.Sum(nil), nil
+	case JWTRS256:
+		key, ok := s.Key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case JWTES256:
+		key, ok := s.Key.(*ecdsa.PrivateKey)
+		if !ok || key.Curve.Params().BitSize != 256 {
+			return nil, ErrInvalidKey
+		}
+		r, sig, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// signature is a fixed size concatenation of r and s
+		out := make([]byte, 64)
+		rb, sb := r.Bytes(), sig.Bytes()
+		copy(out[32-len(rb):32], rb)
+		copy(out[64-len(sb):], sb)
+		return out, nil
+	}
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func encodeJWTPart(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
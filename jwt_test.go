@@ -0,0 +1,151 @@
+package gocouch
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func splitJWT(t *testing.T, token string) (payload string, claims map[string]interface{}, signature []byte) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Malformed token: %v\n", token)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("Error: %v\n", err)
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		t.Fatalf("Error: %v\n", err)
+	}
+	if signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		t.Fatalf("Error: %v\n", err)
+	}
+	return parts[0] + "." + parts[1], claims, signature
+}
+
+func TestJWTSigner_Token(t *testing.T) {
+	secret := []byte("secret")
+	signer := JWTSigner{Algorithm: JWTHS256, Key: secret, Subject: "service", Roles: []string{"_admin"}}
+	token, err := signer.Token()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	payload, claims, signature := splitJWT(t, token.Value)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		t.Log("Invalid HS256 signature")
+		t.Fail()
+	}
+	if claims["sub"] != "service" || len(claims["_couchdb.roles"].([]interface{})) != 1 {
+		t.Logf("Unexpected claims: %v\n", claims)
+		t.Fail()
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	signer = JWTSigner{Algorithm: JWTRS256, Key: rsaKey, Subject: "service"}
+	if token, err = signer.Token(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	payload, _, signature = splitJWT(t, token.Value)
+	digest := sha256.Sum256([]byte(payload))
+	if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Logf("Invalid RS256 signature: %v\n", err)
+		t.Fail()
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer = JWTSigner{Algorithm: JWTES256, Key: ecKey, Subject: "service"}
+	if token, err = signer.Token(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	payload, _, signature = splitJWT(t, token.Value)
+	digest = sha256.Sum256([]byte(payload))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if len(signature) != 64 || !ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s) {
+		t.Log("Invalid ES256 signature")
+		t.Fail()
+	}
+
+	signer = JWTSigner{Algorithm: "hs256", Key: secret}
+	if token, err = signer.Token(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(strings.Split(token.Value, ".")[0])
+	if !strings.Contains(string(header), `"alg":"HS256"`) {
+		t.Logf("Algorithm is not normalised: %s\n", header)
+		t.Fail()
+	}
+
+	signer = JWTSigner{Algorithm: JWTRS256, Key: secret}
+	if _, err := signer.Token(); err != ErrInvalidKey {
+		t.Logf("Expected ErrInvalidKey, got: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestJWTAuth_Refresh(t *testing.T) {
+	issued := 0
+	auth := NewJWTAuth(TokenSourceFunc(func() (*Token, error) {
+		issued++
+		return &Token{Value: string(rune('a' + issued)), Expiry: time.Now().Add(90 * time.Second)}, nil
+	}))
+	req, _ := http.NewRequest("GET", "http://localhost:5984/", nil)
+	if err := auth.authorize(nil, req); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if err := auth.authorize(nil, req); err != nil || issued != 1 {
+		t.Logf("Token must be reused, issued: %v, error: %v\n", issued, err)
+		t.Fail()
+	}
+	auth.RefreshBefore = 2 * time.Minute
+	if err := auth.authorize(nil, req); err != nil || issued != 2 {
+		t.Logf("Token must be refreshed before expiry, issued: %v, error: %v\n", issued, err)
+		t.Fail()
+	}
+	auth.RefreshBefore = 0
+	retry, err := auth.handleResponse(nil, req, &http.Response{StatusCode: http.StatusUnauthorized}, true)
+	if err != nil || !retry || issued != 3 {
+		t.Logf("Rejected token must be refreshed, issued: %v, error: %v\n", issued, err)
+		t.Fail()
+	}
+	// request carrying outdated token is replayed without refresh
+	retry, err = auth.handleResponse(nil, req, &http.Response{StatusCode: http.StatusUnauthorized}, true)
+	if err != nil || !retry || issued != 3 {
+		t.Logf("Token must not be refreshed twice, issued: %v, error: %v\n", issued, err)
+		t.Fail()
+	}
+}
+
+func TestJWTAuth_EmptyToken(t *testing.T) {
+	auth := NewJWTAuth(TokenSourceFunc(func() (*Token, error) {
+		return &Token{}, nil
+	}))
+	req, _ := http.NewRequest("GET", "http://localhost:5984/", nil)
+	if err := auth.authorize(nil, req); err != ErrEmptyToken || req.Header.Get("Authorization") != "" {
+		t.Logf("Expected ErrEmptyToken, got: %v\n", err)
+		t.Fail()
+	}
+}
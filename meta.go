@@ -0,0 +1,94 @@
+package gocouch
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// documentMeta returns `_id` and `_rev` of a map or a struct, fields of
+// structs are found by json tags including ones of embedded structs
+func documentMeta(doc interface{}) (id, rev string) {
+	if d, ok := doc.(Identifiable); ok {
+		return d.DocID(), d.DocRev()
+	}
+	if m, ok := doc.(map[string]interface{}); ok {
+		id, _ = m["_id"].(string)
+		rev, _ = m["_rev"].(string)
+		return
+	}
+	v := reflect.Indirect(reflect.ValueOf(doc))
+	if f := metaField(v, "_id"); f.IsValid() {
+		id = f.String()
+	}
+	if f := metaField(v, "_rev"); f.IsValid() {
+		rev = f.String()
+	}
+	return
+}
+
+// setDocumentMeta updates `_id` and `_rev` of a map or a pointer to struct,
+// it reports whether document has both of them
+func setDocumentMeta(doc interface{}, id, rev string) bool {
+	if d, ok := doc.(Identifiable); ok {
+		d.SetDocID(id)
+		d.SetDocRev(rev)
+		return true
+	}
+	if m, ok := doc.(map[string]interface{}); ok {
+		m["_id"], m["_rev"] = id, rev
+		return true
+	}
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Ptr {
+		return false
+	}
+	v = v.Elem()
+	idField, revField := metaField(v, "_id"), metaField(v, "_rev")
+	if idField.IsValid() && idField.CanSet() {
+		idField.SetString(id)
+	}
+	if revField.IsValid() && revField.CanSet() {
+		revField.SetString(rev)
+	}
+	return idField.IsValid() && revField.IsValid()
+}
+
+// metaField finds string field of struct by json name
+func metaField(v reflect.Value, name string) reflect.Value {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == name && field.Type.Kind() == reflect.String {
+			return v.Field(i)
+		}
+		if field.Anonymous && tag == "" {
+			embedded := v.Field(i)
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if f := metaField(embedded, name); f.IsValid() {
+				return f
+			}
+		}
+	}
+	return reflect.Value{}
+}
+
+// docPath escapes document id to be used in urls keeping prefixes of
+// design and local documents
+func docPath(id string) string {
+	for _, prefix := range []string{"_design/", "_local/"} {
+		if strings.HasPrefix(id, prefix) {
+			return prefix + url.PathEscape(strings.TrimPrefix(id, prefix))
+		}
+	}
+	return url.PathEscape(id)
+}
@@ -0,0 +1,49 @@
+package gocouch
+
+import "testing"
+
+type metaBase struct {
+	ID  string `json:"_id,omitempty"`
+	Rev string `json:"_rev,omitempty"`
+}
+
+type metaDoc struct {
+	metaBase
+	Name string `json:"name"`
+}
+
+func TestSetDocumentMeta(t *testing.T) {
+	doc := &metaDoc{Name: "milk"}
+	if !setDocumentMeta(doc, "id", "1-a") {
+		t.Log("Embedded fields are not found")
+		t.Fail()
+	}
+	if id, rev := documentMeta(doc); id != "id" || rev != "1-a" {
+		t.Logf("Unexpected meta: %v %v\n", id, rev)
+		t.Fail()
+	}
+	if setDocumentMeta(TestDoc{}, "id", "1-a") {
+		t.Log("Document without fields must be reported")
+		t.Fail()
+	}
+	m := map[string]interface{}{"_id": "id"}
+	setDocumentMeta(m, "id", "2-b")
+	if _, rev := documentMeta(m); rev != "2-b" {
+		t.Logf("Unexpected map: %v\n", m)
+		t.Fail()
+	}
+}
+
+func TestDocPath(t *testing.T) {
+	for id, expected := range map[string]string{
+		"doc":             "doc",
+		"a/b c":           "a%2Fb%20c",
+		"_design/my view": "_design/my%20view",
+		"_local/check":    "_local/check",
+	} {
+		if path := docPath(id); path != expected {
+			t.Logf("Expected %v, got %v\n", expected, path)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package gocouch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Patch errors
+var (
+	ErrPatchPath      = errors.New("Invalid JSON Patch path")
+	ErrPatchOperation = errors.New("Unknown JSON Patch operation")
+	ErrPatchTest      = errors.New("JSON Patch test failed")
+	ErrPatchNotObject = errors.New("Patched document is not an object")
+)
+
+// Patch changes decoded JSON document, document passed to Apply is left
+// intact
+type Patch interface {
+	Apply(doc interface{}) (interface{}, error)
+}
+
+// PatchOperation is a single operation of JSON Patch
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON emits value of add, replace and test operations even when
+// it's null, since it's required for them
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	type operation PatchOperation
+	if op.Op != "add" && op.Op != "replace" && op.Op != "test" {
+		return json.Marshal(operation(op))
+	}
+	return json.Marshal(struct {
+		operation
+		Value interface{} `json:"value"`
+	}{operation(op), op.Value})
+}
+
+// JSONPatch is an RFC 6902 patch, operations are applied in order and the
+// patch fails as a whole when any of them fails
+type JSONPatch []PatchOperation
+
+// MergePatch is an RFC 7386 patch, nil values remove fields
+type MergePatch map[string]interface{}
+
+// PatchResult describes result of patching a single document
+type PatchResult struct {
+	ID  string
+	Rev string
+	Err error
+}
+
+// Apply applies operations to a copy of the document
+func (p JSONPatch) Apply(doc interface{}) (interface{}, error) {
+	doc, err := normalizeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range p {
+		if doc, err = op.apply(doc); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func (op PatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := normalizeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "test" {
+			current, err := pointerGet(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, ErrPatchTest
+			}
+			return doc, nil
+		}
+		if op.Op == "replace" && len(path) > 0 {
+			if doc, _, err = pointerRemove(doc, path); err != nil {
+				return nil, err
+			}
+		}
+		return pointerAdd(doc, path, value)
+	case "remove":
+		doc, _, err = pointerRemove(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if op.Op == "move" {
+			// value can't be moved into itself
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, ErrPatchPath
+			}
+			doc, value, err = pointerRemove(doc, from)
+		} else {
+			if value, err = pointerGet(doc, from); err == nil {
+				value, err = normalizeJSON(value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+	}
+	return nil, ErrPatchOperation
+}
+
+// Apply merges patch into a copy of the document
+func (p MergePatch) Apply(doc interface{}) (interface{}, error) {
+	doc, err := normalizeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := normalizeJSON(map[string]interface{}(p))
+	if err != nil {
+		return nil, err
+	}
+	return mergePatch(doc, patch), nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// normalizeJSON returns a deep copy of value consisting of types produced
+// by json decoder, so values may be compared
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parsePointer splits RFC 6901 JSON pointer to unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, ErrPatchPath
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses index of array element, end allows "-" and index equal
+// to the length which point past the last element
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, ErrPatchPath
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > length || (i == length && !end) {
+		return 0, ErrPatchPath
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, ErrPatchPath
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, ErrPatchPath
+		}
+	}
+	return doc, nil
+}
+
+// pointerUpdate replaces the container holding the last token of the path
+// with the result of fn, containers are changed in place where possible
+func pointerUpdate(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, ErrPatchPath
+		}
+		child, err := pointerUpdate(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := pointerUpdate(node[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+	return nil, ErrPatchPath
+}
+
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, ErrPatchPath
+	})
+}
+
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, ErrPatchPath
+	}
+	var removed interface{}
+	doc, err := pointerUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, ErrPatchPath
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, ErrPatchPath
+	})
+	return doc, removed, err
+}
+
+// applyDocumentPatch patches document keeping its id and revision
+func applyDocumentPatch(doc map[string]interface{}, patch Patch) (map[string]interface{}, error) {
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+	out, ok := patched.(map[string]interface{})
+	if !ok {
+		return nil, ErrPatchNotObject
+	}
+	for _, field := range []string{"_id", "_rev"} {
+		if value, ok := doc[field]; ok {
+			out[field] = value
+		} else {
+			delete(out, field)
+		}
+	}
+	return out, nil
+}
+
+// Patch applies JSON Patch or Merge Patch to the latest revision of the
+// document retrying on conflicts, see UpdateFunc for options
+func (db *Database) Patch(id string, patch Patch, options *UpdateOptions) (map[string]interface{}, string, error) {
+	return db.UpdateFunc(id, func(doc map[string]interface{}, exists bool) error {
+		patched, err := applyDocumentPatch(doc, patch)
+		if err != nil {
+			return err
+		}
+		for k := range doc {
+			delete(doc, k)
+		}
+		for k, v := range patched {
+			doc[k] = v
+		}
+		return nil
+	}, options)
+}
+
+// PatchMany patches many documents fetching them with `_bulk_get` and
+// saving with `_bulk_docs` (CouchDB 2.x+). Conflicting documents are
+// patched again according to options. Results are sorted by id, error of
+// the whole request is returned only if it failed
+func (db *Database) PatchMany(patches map[string]Patch, options *UpdateOptions) ([]PatchResult, error) {
+	opts := options.withDefaults()
+	results := make(map[string]*PatchResult, len(patches))
+	pending := make([]string, 0, len(patches))
+	for id := range patches {
+		results[id] = &PatchResult{ID: id}
+		pending = append(pending, id)
+	}
+	sort.Strings(pending)
+	backoff := opts.Backoff
+	for attempt := 0; len(pending) > 0; attempt++ {
+		docs, errs, err := db.bulkGetLatest(pending)
+		if err != nil {
+			return nil, err
+		}
+		var (
+			batch []map[string]interface{}
+			ids   []string
+		)
+		for _, id := range pending {
+			doc, err := docs[id], errs[id]
+			if doc == nil && err == nil {
+				err = bulkError("not_found", "missing")
+			}
+			if isStatus(err, 404) && opts.Upsert {
+				doc, err = map[string]interface{}{"_id": id}, nil
+			}
+			results[id].Err = err
+			if err != nil {
+				continue
+			}
+			if doc, results[id].Err = applyDocumentPatch(doc, patches[id]); results[id].Err == nil {
+				batch = append(batch, doc)
+				ids = append(ids, id)
+			}
+		}
+		pending = pending[:0]
+		if len(batch) == 0 {
+			break
+		}
+		updates, err := db.Update(batch, false, true, false)
+		if err != nil {
+			return nil, err
+		}
+		for i, update := range updates {
+			if i >= len(ids) {
+				break
+			}
+			result := results[ids[i]]
+			switch {
+			case update.Error == "":
+				result.Rev, result.Err = update.Rev, nil
+			case update.Error == "conflict" && attempt < opts.MaxRetries:
+				pending = append(pending, ids[i])
+			case update.Error == "conflict":
+				result.Err = ErrTooManyConflicts
+			default:
+				result.Err = bulkError(update.Error, update.Reason)
+			}
+		}
+		if len(pending) > 0 {
+			sleepBackoff(&backoff)
+		}
+	}
+	ids := make([]string, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out := make([]PatchResult, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, *results[id])
+	}
+	return out, nil
+}
+
+// bulkGetLatest fetches the latest revisions of documents, errors of
+// individual documents are returned separately
+func (db *Database) bulkGetLatest(ids []string) (map[string]map[string]interface{}, map[string]error, error) {
+	type docRef struct {
+		ID string `json:"id"`
+	}
+	refs := make([]docRef, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, docRef{id})
+	}
+	payload, err := json.Marshal(map[string][]docRef{"docs": refs})
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := db.conn.request("POST", queryURL(db.Name, "_bulk_get"),
+		map[string]string{"Content-Type": appJSON, "Accept": appJSON}, bytes.NewReader(payload), db.auth, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var result bulkGetResult
+	if err := parseBody(resp, &result); err != nil {
+		return nil, nil, err
+	}
+	docs := make(map[string]map[string]interface{}, len(ids))
+	errs := make(map[string]error)
+	for _, item := range result.Results {
+		for _, doc := range item.Docs {
+			if doc.Error != nil {
+				errs[item.ID] = bulkError(doc.Error.Error, doc.Error.Reason)
+				continue
+			}
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(doc.Ok, &decoded); err != nil {
+				return nil, nil, err
+			}
+			docs[item.ID] = decoded
+		}
+	}
+	return docs, errs, nil
+}
@@ -0,0 +1,121 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, data string) interface{} {
+	var out interface{}
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatalf("Error: %v\n", err)
+	}
+	return out
+}
+
+func TestJSONPatch_Apply(t *testing.T) {
+	cases := []struct {
+		doc, patch, expected string
+		err                  error
+	}{
+		{`{"a":1}`, `[{"op":"add","path":"/b","value":[1,2]}]`, `{"a":1,"b":[1,2]}`, nil},
+		{`{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2},{"op":"add","path":"/a/-","value":4}]`,
+			`{"a":[1,2,3,4]}`, nil},
+		{`{"a":{"b":1},"c":2}`, `[{"op":"remove","path":"/a/b"},{"op":"replace","path":"/c","value":"x"}]`,
+			`{"a":{},"c":"x"}`, nil},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a/b","path":"/c"},{"op":"copy","from":"/c","path":"/d"}]`,
+			`{"a":{},"c":1,"d":1}`, nil},
+		{`{"a/b":{"~":1}}`, `[{"op":"test","path":"/a~1b/~0","value":1}]`, `{"a/b":{"~":1}}`, nil},
+		{`{"a":1}`, `[{"op":"test","path":"/a","value":2}]`, ``, ErrPatchTest},
+		{`{"a":1}`, `[{"op":"replace","path":"/b","value":2}]`, ``, ErrPatchPath},
+		{`{"a":[1]}`, `[{"op":"add","path":"/a/01","value":2}]`, ``, ErrPatchPath},
+		{`{"a":{}}`, `[{"op":"move","from":"/a","path":"/a/b"}]`, ``, ErrPatchPath},
+		{`{"a":1}`, `[{"op":"unknown","path":"/a"}]`, ``, ErrPatchOperation},
+	}
+	for _, c := range cases {
+		var patch JSONPatch
+		if err := json.Unmarshal([]byte(c.patch), &patch); err != nil {
+			t.Fatalf("Error: %v\n", err)
+		}
+		doc := decodeJSON(t, c.doc)
+		result, err := patch.Apply(doc)
+		if err != c.err {
+			t.Logf("Patch %v: expected error %v, got %v\n", c.patch, c.err, err)
+			t.Fail()
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(result, decodeJSON(t, c.expected)) {
+			t.Logf("Patch %v: expected %v, got %v\n", c.patch, c.expected, result)
+			t.Fail()
+		}
+		if !reflect.DeepEqual(doc, decodeJSON(t, c.doc)) {
+			t.Logf("Patch %v modified original document\n", c.patch)
+			t.Fail()
+		}
+	}
+}
+
+func TestPatchOperation_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(JSONPatch{
+		{Op: "replace", Path: "/a", Value: nil},
+		{Op: "remove", Path: "/b"},
+		{Op: "move", Path: "/c", From: "/d"},
+	})
+	expected := `[{"op":"replace","path":"/a","value":null},{"op":"remove","path":"/b"},{"op":"move","path":"/c","from":"/d"}]`
+	if err != nil || string(data) != expected {
+		t.Logf("Error: %v, patch: %s\n", err, data)
+		t.Fail()
+	}
+}
+
+func TestMergePatch_Apply(t *testing.T) {
+	doc := decodeJSON(t, `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"]}`)
+	var patch MergePatch
+	json.Unmarshal([]byte(`{"title":"Hello!","author":{"familyName":null},"tags":["example"],"phone":"555"}`), &patch)
+	result, err := patch.Apply(doc)
+	expected := decodeJSON(t, `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"phone":"555"}`)
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Logf("Error: %v, result: %v\n", err, result)
+		t.Fail()
+	}
+}
+
+func TestDatabase_Patch(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("patch_test", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	if _, err := db.Put("doc", map[string]interface{}{"count": 1, "tags": []string{"a"}}); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	doc, rev, err := db.Patch("doc", JSONPatch{{Op: "add", Path: "/tags/-", Value: "b"}}, nil)
+	if err != nil || rev[:2] != "2-" || len(doc["tags"].([]interface{})) != 2 {
+		t.Logf("Error: %v, doc: %v\n", err, doc)
+		t.Fail()
+	}
+	results, err := db.PatchMany(map[string]Patch{
+		"doc":     MergePatch{"count": 2},
+		"missing": MergePatch{"count": 1},
+		"invalid": JSONPatch{{Op: "remove", Path: "/none"}},
+	}, nil)
+	if err != nil || len(results) != 3 {
+		t.Logf("Error: %v, results: %v\n", err, results)
+		t.Fail()
+		return
+	}
+	if results[0].ID != "doc" || results[0].Err != nil || results[0].Rev[:2] != "3-" {
+		t.Logf("Unexpected result: %+v\n", results[0])
+		t.Fail()
+	}
+	if !isStatus(results[2].Err, 404) {
+		t.Logf("Expected not found, got: %+v\n", results[2])
+		t.Fail()
+	}
+}
@@ -0,0 +1,407 @@
+package gocouch
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// replicationIDVersion is a version of replication log format
+const replicationIDVersion = 3
+
+// replicationHistoryLimit is a maximum count of sessions kept in replication log
+const replicationHistoryLimit = 50
+
+// ReplicationOptions describe replication made by the client between two databases
+type ReplicationOptions struct {
+	// Continuous makes replication follow source changes until context is cancelled
+	Continuous bool
+	// Filter is a name of filter function in "ddoc/filter" form
+	Filter string
+	// QueryParams are passed to the filter function
+	QueryParams map[string]string
+	// DocIDs limits replication to given documents
+	DocIDs []string
+	// Selector limits replication to documents matching Mango selector
+	Selector map[string]interface{}
+	// BatchSize is a count of changes processed at once
+	BatchSize int
+	// PollTimeout is used by continuous replication waiting for new changes
+	PollTimeout time.Duration
+}
+
+// ReplicationStats provides counters of client side replication
+type ReplicationStats struct {
+	SessionID        string   `json:"session_id"`
+	StartLastSeq     Sequence `json:"start_last_seq"`
+	EndLastSeq       Sequence `json:"end_last_seq"`
+	RecordedSeq      Sequence `json:"recorded_seq"`
+	StartTime        string   `json:"start_time"`
+	EndTime          string   `json:"end_time"`
+	MissingChecked   int      `json:"missing_checked"`
+	MissingFound     int      `json:"missing_found"`
+	DocsRead         int      `json:"docs_read"`
+	DocsWritten      int      `json:"docs_written"`
+	DocWriteFailures int      `json:"doc_write_failures"`
+}
+
+// ReplicationLog is a checkpoint document stored in `_local/<replication id>`
+// on both source and target
+type ReplicationLog struct {
+	ID                   string             `json:"_id"`
+	Rev                  string             `json:"_rev,omitempty"`
+	SessionID            string             `json:"session_id"`
+	SourceLastSeq        Sequence           `json:"source_last_seq"`
+	ReplicationIDVersion int                `json:"replication_id_version"`
+	History              []ReplicationStats `json:"history"`
+}
+
+type replication struct {
+	source, target *Database
+	opts           ReplicationOptions
+	id             string
+	stats          ReplicationStats
+	sourceLog      ReplicationLog
+	targetLog      ReplicationLog
+}
+
+type bulkGetResult struct {
+	Results []struct {
+		ID   string `json:"id"`
+		Docs []struct {
+			Ok    json.RawMessage `json:"ok"`
+			Error *struct {
+				Rev    string `json:"rev"`
+				Error  string `json:"error"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"docs"`
+	} `json:"results"`
+}
+
+// ReplicateTo copies documents from current database to the target using
+// CouchDB replication protocol implemented on the client side, so source and
+// target don't need to see each other. Progress is saved in replication logs
+// on both databases and next run continues from the last checkpoint
+func (db *Database) ReplicateTo(ctx context.Context, target *Database, opts ReplicationOptions) (*ReplicationStats, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.PollTimeout <= 0 {
+		opts.PollTimeout = 30 * time.Second
+	}
+	r := &replication{source: db, target: target, opts: opts}
+	id, err := r.replicationID()
+	if err != nil {
+		return nil, err
+	}
+	r.id = id
+	session := make([]byte, 16)
+	if _, err := rand.Read(session); err != nil {
+		return nil, err
+	}
+	r.stats.SessionID = hex.EncodeToString(session)
+	r.stats.StartTime = time.Now().UTC().Format(time.RFC1123)
+	since, err := r.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	r.stats.StartLastSeq = since
+	r.stats.RecordedSeq = since
+	if err := r.run(ctx, since); err != nil {
+		return &r.stats, err
+	}
+	return &r.stats, nil
+}
+
+// replicationID returns identifier of replication used in checkpoint
+// documents, it depends on databases and options that change replicated set
+func (r *replication) replicationID() (string, error) {
+	params, err := json.Marshal([]interface{}{
+		r.opts.Filter, r.opts.QueryParams, r.opts.DocIDs, r.opts.Selector, r.opts.Continuous,
+	})
+	if err != nil {
+		return "", err
+	}
+	hash := md5.New()
+	fmt.Fprintf(hash, "%s%s\n%s%s\n", r.source.conn.url, queryURL(r.source.Name),
+		r.target.conn.url, queryURL(r.target.Name))
+	hash.Write(params)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// loadCheckpoint compares logs of both databases and returns sequence
+// replication should start from
+func (r *replication) loadCheckpoint() (Sequence, error) {
+	logID := "_local/" + r.id
+	r.sourceLog = ReplicationLog{ID: logID}
+	r.targetLog = ReplicationLog{ID: logID}
+	if err := r.source.Get(logID, &r.sourceLog, nil); err != nil && !isStatus(err, 404) {
+		return "", err
+	}
+	if err := r.target.Get(logID, &r.targetLog, nil); err != nil && !isStatus(err, 404) {
+		return "", err
+	}
+	if r.sourceLog.SessionID == "" || r.targetLog.SessionID == "" {
+		return "", nil
+	}
+	if r.sourceLog.SessionID == r.targetLog.SessionID {
+		return r.sourceLog.SourceLastSeq, nil
+	}
+	// logs diverged, look for the latest session known by both sides
+	for _, s := range r.sourceLog.History {
+		for _, t := range r.targetLog.History {
+			if s.SessionID == t.SessionID {
+				return s.RecordedSeq, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// saveCheckpoint writes replication log on both databases
+func (r *replication) saveCheckpoint(seq Sequence) error {
+	r.stats.RecordedSeq = seq
+	r.stats.EndLastSeq = seq
+	r.stats.EndTime = time.Now().UTC().Format(time.RFC1123)
+	history := []ReplicationStats{r.stats}
+	for _, s := range r.sourceLog.History {
+		if s.SessionID != r.stats.SessionID {
+			history = append(history, s)
+		}
+	}
+	if len(history) > replicationHistoryLimit {
+		history = history[:replicationHistoryLimit]
+	}
+	for _, log := range []struct {
+		db  *Database
+		doc *ReplicationLog
+	}{{r.source, &r.sourceLog}, {r.target, &r.targetLog}} {
+		log.doc.SessionID = r.stats.SessionID
+		log.doc.SourceLastSeq = seq
+		log.doc.ReplicationIDVersion = replicationIDVersion
+		log.doc.History = history
+		rev, err := log.db.Put(log.doc.ID, log.doc)
+		if err != nil {
+			return err
+		}
+		log.doc.Rev = rev
+	}
+	return nil
+}
+
+func (r *replication) run(ctx context.Context, since Sequence) error {
+	conn := r.source.conn.streaming()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch, err := r.changes(ctx, conn, since)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if len(batch.Results) > 0 {
+			if err := r.replicateBatch(ctx, batch.Results); err != nil {
+				return err
+			}
+		}
+		if batch.LastSeq != "" && batch.LastSeq != since {
+			since = batch.LastSeq
+			if err := r.saveCheckpoint(since); err != nil {
+				return err
+			}
+		}
+		if !r.opts.Continuous && len(batch.Results) < r.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// changes fetches next batch of source changes with all leaf revisions
+func (r *replication) changes(ctx context.Context, conn *connection, since Sequence) (*changesBatch, error) {
+	options := Options{"style": "all_docs", "limit": r.opts.BatchSize}
+	if since != "" {
+		options["since"] = since
+	}
+	if r.opts.Continuous {
+		options["feed"] = FeedLongpoll
+		options["timeout"] = int64(r.opts.PollTimeout / time.Millisecond)
+	}
+	var body interface{}
+	switch {
+	case len(r.opts.DocIDs) > 0:
+		options["filter"] = "_doc_ids"
+		body = map[string]interface{}{"doc_ids": r.opts.DocIDs}
+	case r.opts.Selector != nil:
+		options["filter"] = "_selector"
+		body = map[string]interface{}{"selector": r.opts.Selector}
+	case r.opts.Filter != "":
+		options["filter"] = r.opts.Filter
+		for k, v := range r.opts.QueryParams {
+			options[k] = v
+		}
+	}
+	method, headers := "GET", map[string]string(nil)
+	payload, _, err := encodeData(body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		method, headers = "POST", map[string]string{"Content-Type": appJSON}
+	}
+	resp, err := conn.requestContext(ctx, method,
+		queryURL(r.source.Name, "_changes")+encodeOptions(options), headers, payload, r.source.auth)
+	if err != nil {
+		return nil, err
+	}
+	var batch changesBatch
+	if err := parseBody(resp, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// replicateBatch finds revisions missing on target, fetches and writes them
+func (r *replication) replicateBatch(ctx context.Context, changes []Change) error {
+	revs := make(map[string][]string)
+	for _, change := range changes {
+		for _, rev := range change.Changes {
+			revs[change.ID] = append(revs[change.ID], rev["rev"])
+		}
+		r.stats.MissingChecked += len(change.Changes)
+	}
+	diff, err := r.target.GetRevsDiff(revs)
+	if err != nil {
+		return err
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+	missing := make(map[string][]string)
+	for id, d := range diff {
+		missing[id] = d["missing"]
+		r.stats.MissingFound += len(d["missing"])
+	}
+	docs, err := r.fetch(ctx, missing)
+	if err != nil {
+		return err
+	}
+	r.stats.DocsRead += len(docs)
+	if len(docs) == 0 {
+		return nil
+	}
+	results, err := r.target.Update(docs, false, false, true)
+	if err != nil {
+		return err
+	}
+	failures := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failures++
+		}
+	}
+	r.stats.DocWriteFailures += failures
+	r.stats.DocsWritten += len(docs) - failures
+	return nil
+}
+
+// fetch loads missing revisions with their history and attachments, using
+// `_bulk_get` when source supports it and `open_revs` otherwise
+func (r *replication) fetch(ctx context.Context, missing map[string][]string) ([]json.RawMessage, error) {
+	docs, err := r.bulkGet(ctx, missing)
+	if err == nil {
+		return docs, nil
+	}
+	if !isStatus(err, 400) && !isStatus(err, 404) && !isStatus(err, 405) {
+		return nil, err
+	}
+	ids := make([]string, 0, len(missing))
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		revDocs, err := r.openRevs(ctx, id, missing[id])
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, revDocs...)
+	}
+	return docs, nil
+}
+
+func (r *replication) bulkGet(ctx context.Context, missing map[string][]string) ([]json.RawMessage, error) {
+	type docRef struct {
+		ID  string `json:"id"`
+		Rev string `json:"rev"`
+	}
+	var request struct {
+		Docs []docRef `json:"docs"`
+	}
+	for id, revs := range missing {
+		for _, rev := range revs {
+			request.Docs = append(request.Docs, docRef{id, rev})
+		}
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	options := Options{"revs": true, "attachments": true, "latest": true}
+	resp, err := r.source.conn.requestContext(ctx, "POST", queryURL(r.source.Name, "_bulk_get")+encodeOptions(options),
+		map[string]string{"Content-Type": appJSON, "Accept": appJSON}, bytes.NewReader(payload), r.source.auth)
+	if err != nil {
+		return nil, err
+	}
+	var result bulkGetResult
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	var docs []json.RawMessage
+	for _, item := range result.Results {
+		for _, doc := range item.Docs {
+			if doc.Error != nil {
+				return nil, fmt.Errorf("Failed to fetch %s@%s: %s - %s",
+					item.ID, doc.Error.Rev, doc.Error.Error, doc.Error.Reason)
+			}
+			docs = append(docs, doc.Ok)
+		}
+	}
+	return docs, nil
+}
+
+func (r *replication) openRevs(ctx context.Context, id string, revs []string) ([]json.RawMessage, error) {
+	openRevs, err := json.Marshal(revs)
+	if err != nil {
+		return nil, err
+	}
+	options := Options{"open_revs": string(openRevs), "revs": true, "attachments": true, "latest": true}
+	resp, err := r.source.conn.requestContext(ctx, "GET", queryURL(r.source.Name, id)+encodeOptions(options),
+		map[string]string{"Accept": appJSON}, nil, r.source.auth)
+	if err != nil {
+		return nil, err
+	}
+	var result []struct {
+		Ok      json.RawMessage `json:"ok"`
+		Missing string          `json:"missing"`
+	}
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	var docs []json.RawMessage
+	for _, item := range result {
+		if item.Ok != nil {
+			docs = append(docs, item.Ok)
+		}
+	}
+	return docs, nil
+}
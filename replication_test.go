@@ -0,0 +1,91 @@
+package gocouch
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDatabase_ReplicateTo(t *testing.T) {
+	srv := getConnection(t)
+	source, err := srv.MustGetDatabase("client_replication_source", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer source.Delete()
+	target, err := srv.MustGetDatabase("client_replication_target", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer target.Delete()
+	rev, err := source.Put("with_attachment", map[string]string{"field": "value"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	_, err = source.SaveAttachment("with_attachment", rev, &Attachment{
+		Name: "note.txt", ContentType: "text/plain", Body: bytes.NewBufferString("attachment")})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	source.Put("other", map[string]string{"field": "other"})
+	stats, err := source.ReplicateTo(context.Background(), target, ReplicationOptions{})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if stats.DocsWritten != 2 || stats.DocWriteFailures != 0 {
+		t.Logf("Unexpected stats: %#v\n", stats)
+		t.Fail()
+		return
+	}
+	if _, err := target.AttachmentInfo("with_attachment", "note.txt"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	// next run starts from checkpoint and has nothing to replicate
+	stats, err = source.ReplicateTo(context.Background(), target, ReplicationOptions{})
+	if err != nil || stats.StartLastSeq == "" || stats.MissingChecked != 0 {
+		t.Logf("Error: %v, stats: %#v\n", err, stats)
+		t.Fail()
+	}
+}
+
+func TestDatabase_ReplicateToDocIDs(t *testing.T) {
+	srv := getConnection(t)
+	source, err := srv.MustGetDatabase("client_replication_ids_source", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer source.Delete()
+	target, err := srv.MustGetDatabase("client_replication_ids_target", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer target.Delete()
+	source.Put("wanted", map[string]string{})
+	source.Put("skipped", map[string]string{})
+	if _, err := source.ReplicateTo(context.Background(), target,
+		ReplicationOptions{DocIDs: []string{"wanted"}}); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if _, _, err := target.Exists("skipped", nil); err == nil {
+		t.Log("Document outside of doc_ids was replicated")
+		t.Fail()
+	}
+}
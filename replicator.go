@@ -0,0 +1,214 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// replicatorDB is a name of database storing persistent replications
+const replicatorDB = "_replicator"
+
+// ReplicationEndpoint describes source or target of a replication, headers
+// are sent by the server with every request to this endpoint
+type ReplicationEndpoint struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// ReplicationRequest describes replication performed by the server, it's used
+// both by `_replicate` and as a body of `_replicator` documents
+type ReplicationRequest struct {
+	Source             ReplicationEndpoint    `json:"source"`
+	Target             ReplicationEndpoint    `json:"target"`
+	Continuous         bool                   `json:"continuous,omitempty"`
+	CreateTarget       bool                   `json:"create_target,omitempty"`
+	Cancel             bool                   `json:"cancel,omitempty"`
+	ReplicationID      string                 `json:"replication_id,omitempty"`
+	Filter             string                 `json:"filter,omitempty"`
+	QueryParams        map[string]string      `json:"query_params,omitempty"`
+	DocIDs             []string               `json:"doc_ids,omitempty"`
+	Selector           map[string]interface{} `json:"selector,omitempty"`
+	SinceSeq           Sequence               `json:"since_seq,omitempty"`
+	UserCtx            *UserContext           `json:"user_ctx,omitempty"`
+	WorkerProcesses    int                    `json:"worker_processes,omitempty"`
+	WorkerBatchSize    int                    `json:"worker_batch_size,omitempty"`
+	HTTPConnections    int                    `json:"http_connections,omitempty"`
+	ConnectionTimeout  int                    `json:"connection_timeout,omitempty"`
+	RetriesPerRequest  int                    `json:"retries_per_request,omitempty"`
+	CheckpointInterval int                    `json:"checkpoint_interval,omitempty"`
+}
+
+// UserContext describes user on behalf of whom an action is performed
+type UserContext struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// ReplicationDoc is a persistent replication stored in `_replicator` database.
+// Fields prefixed with "Replication" and Owner are set by the server
+type ReplicationDoc struct {
+	ID  string `json:"_id,omitempty"`
+	Rev string `json:"_rev,omitempty"`
+	ReplicationRequest
+	Owner                  string                 `json:"owner,omitempty"`
+	ReplicationState       string                 `json:"_replication_state,omitempty"`
+	ReplicationStateTime   string                 `json:"_replication_state_time,omitempty"`
+	ReplicationStateReason string                 `json:"_replication_state_reason,omitempty"`
+	ReplicationDocID       string                 `json:"_replication_id,omitempty"`
+	ReplicationStats       map[string]interface{} `json:"_replication_stats,omitempty"`
+}
+
+// NewReplicationEndpoint returns endpoint with authorisation headers produced
+// by given Auth, so the server can access protected databases. Headers are
+// fixed on creation and never renewed by the server: CookieAuth is stored as
+// basic credentials, or as its current session cookie when it has no
+// password, CredentialsAuth stores credentials current at the moment and
+// JWTAuth its current token. Cookies and tokens expire, so use credentials
+// for persistent and continuous replications
+func NewReplicationEndpoint(endpointURL string, auth Auth) (ReplicationEndpoint, error) {
+	endpoint := ReplicationEndpoint{URL: endpointURL}
+	if auth == nil {
+		return endpoint, nil
+	}
+	if a, ok := auth.(*CookieAuth); ok && a.Password != "" {
+		auth = BasicAuth{a.Username, a.Password}
+	}
+	req := &http.Request{Header: make(http.Header)}
+	if r, ok := auth.(renewer); ok {
+		conn, err := endpointConnection(endpointURL)
+		if err != nil {
+			return endpoint, err
+		}
+		if err := r.authorize(conn, req); err != nil {
+			return endpoint, err
+		}
+	} else {
+		auth.AddAuthHeaders(req)
+	}
+	if len(req.Header) > 0 {
+		endpoint.Headers = make(map[string]string)
+		for k, v := range req.Header {
+			endpoint.Headers[k] = strings.Join(v, ", ")
+		}
+	}
+	return endpoint, nil
+}
+
+// endpointConnection returns connection to the server of database url
+func endpointConnection(endpointURL string) (*connection, error) {
+	u, err := url.Parse(strings.TrimRight(endpointURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if i := strings.LastIndex(u.Path, "/"); i >= 0 {
+		u.Path, u.RawPath = u.Path[:i], ""
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return createConnection(u.String(), 0)
+}
+
+// MarshalJSON encodes endpoint without headers as a plain url, which is
+// understood by all CouchDB versions
+func (e ReplicationEndpoint) MarshalJSON() ([]byte, error) {
+	if len(e.Headers) == 0 {
+		return json.Marshal(e.URL)
+	}
+	type endpoint ReplicationEndpoint
+	return json.Marshal(endpoint(e))
+}
+
+// UnmarshalJSON accepts both url strings and objects
+func (e *ReplicationEndpoint) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		e.Headers = nil
+		return json.Unmarshal(data, &e.URL)
+	}
+	type endpoint ReplicationEndpoint
+	return json.Unmarshal(data, (*endpoint)(e))
+}
+
+func (srv *Server) replicator() *Database {
+	return &Database{conn: srv.conn, auth: srv.auth, Name: replicatorDB}
+}
+
+// CancelReplication stops transient replication started by Replicate, request
+// must be equal to the one replication was started with
+func (srv *Server) CancelReplication(req *ReplicationRequest) (*ReplicationResult, error) {
+	cancel := *req
+	cancel.Cancel = true
+	return srv.Replicate(&cancel)
+}
+
+// CreateReplication stores new persistent replication, if doc's ID is empty
+// it will be generated by the server. ID and Rev of the document are updated
+func (srv *Server) CreateReplication(doc *ReplicationDoc) error {
+	db := srv.replicator()
+	if doc.ID == "" {
+		id, rev, err := db.Insert(doc, false, false)
+		if err != nil {
+			return err
+		}
+		doc.ID, doc.Rev = id, rev
+		return nil
+	}
+	rev, err := db.Put(doc.ID, doc)
+	if err != nil {
+		return err
+	}
+	doc.Rev = rev
+	return nil
+}
+
+// GetReplication fetches persistent replication document with its state
+func (srv *Server) GetReplication(id string) (*ReplicationDoc, error) {
+	var doc ReplicationDoc
+	if err := srv.replicator().Get(id, &doc, nil); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// UpdateReplication saves changes of the replication document, doc must
+// contain the latest revision
+func (srv *Server) UpdateReplication(doc *ReplicationDoc) error {
+	rev, err := srv.replicator().Put(doc.ID, doc)
+	if err != nil {
+		return err
+	}
+	doc.Rev = rev
+	return nil
+}
+
+// ListReplications returns all persistent replications
+func (srv *Server) ListReplications() ([]ReplicationDoc, error) {
+	resp, err := srv.conn.request("GET", queryURL(replicatorDB, "_all_docs")+
+		encodeOptions(Options{"include_docs": true}), nil, nil, srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Rows []struct {
+			ID  string         `json:"id"`
+			Doc ReplicationDoc `json:"doc"`
+		} `json:"rows"`
+	}
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	docs := make([]ReplicationDoc, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		if strings.HasPrefix(row.ID, "_design/") {
+			continue
+		}
+		docs = append(docs, row.Doc)
+	}
+	return docs, nil
+}
+
+// DeleteReplication removes persistent replication which stops it
+func (srv *Server) DeleteReplication(id, rev string) error {
+	_, err := srv.replicator().Del(id, rev)
+	return err
+}
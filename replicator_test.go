@@ -0,0 +1,111 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReplicationEndpoint_MarshalJSON(t *testing.T) {
+	endpoint, _ := NewReplicationEndpoint("http://localhost:5984/db", nil)
+	plain, err := json.Marshal(endpoint)
+	if err != nil || string(plain) != `"http://localhost:5984/db"` {
+		t.Logf("Error: %v, payload: %s\n", err, plain)
+		t.Fail()
+		return
+	}
+	endpoint, _ = NewReplicationEndpoint("http://localhost:5984/db", BasicAuth{"admin", "admin"})
+	payload, err := json.Marshal(endpoint)
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	endpoint = ReplicationEndpoint{}
+	if err := json.Unmarshal(payload, &endpoint); err != nil || endpoint.Headers["Authorization"] == "" {
+		t.Logf("Error: %v, payload: %s\n", err, payload)
+		t.Fail()
+	}
+}
+
+func TestNewReplicationEndpoint_Renewer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/couch/_session" {
+			w.WriteHeader(404)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "token"})
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+	endpoint, err := NewReplicationEndpoint(ts.URL+"/couch/db", NewCookieAuth("admin", "admin"))
+	if err != nil || endpoint.Headers["Authorization"] == "" || endpoint.Headers["Cookie"] != "" {
+		t.Logf("Error: %v, endpoint: %+v\n", err, endpoint)
+		t.Fail()
+	}
+	// session without password can only be stored as a cookie
+	endpoint, err = NewReplicationEndpoint(ts.URL+"/couch/db", NewCookieAuth("admin", ""))
+	if err != nil || endpoint.Headers["Cookie"] != sessionCookie+"=token" {
+		t.Logf("Error: %v, endpoint: %+v\n", err, endpoint)
+		t.Fail()
+	}
+	endpoint, err = NewReplicationEndpoint(ts.URL+"/db", NewJWTAuth(TokenSourceFunc(func() (*Token, error) {
+		return &Token{Value: "jwt"}, nil
+	})))
+	if err != nil || endpoint.Headers["Authorization"] != "Bearer jwt" {
+		t.Logf("Error: %v, endpoint: %+v\n", err, endpoint)
+		t.Fail()
+	}
+}
+
+func TestServer_CreateReplication(t *testing.T) {
+	srv := getConnection(t)
+	if _, err := srv.MustGetDatabase(replicatorDB, BasicAuth{"admin", "admin"}); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	source, err := srv.MustGetDatabase("persistent_source", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer source.Delete()
+	from, _ := NewReplicationEndpoint("http://localhost:5984/persistent_source", srv.auth)
+	to, _ := NewReplicationEndpoint("http://localhost:5984/persistent_target", srv.auth)
+	doc := ReplicationDoc{
+		ID: "persistent_replication",
+		ReplicationRequest: ReplicationRequest{
+			Source:       from,
+			Target:       to,
+			CreateTarget: true,
+			Continuous:   true,
+		},
+	}
+	if err := srv.CreateReplication(&doc); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	found, err := srv.GetReplication(doc.ID)
+	if err != nil || found.Source.URL != doc.Source.URL || !found.Continuous {
+		t.Logf("Error: %v, doc: %#v\n", err, found)
+		t.Fail()
+		return
+	}
+	list, err := srv.ListReplications()
+	if err != nil || len(list) < 1 {
+		t.Logf("Error: %v, list: %#v\n", err, list)
+		t.Fail()
+		return
+	}
+	if err := srv.DeleteReplication(found.ID, found.Rev); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+	if target, err := srv.GetDatabase("persistent_target", BasicAuth{"admin", "admin"}); err == nil {
+		target.Delete()
+	}
+}
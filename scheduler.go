@@ -0,0 +1,202 @@
+package gocouch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Replication states reported by the scheduler
+const (
+	ReplicationInitializing = "initializing"
+	ReplicationPending      = "pending"
+	ReplicationRunning      = "running"
+	ReplicationCrashing     = "crashing"
+	ReplicationError        = "error"
+	ReplicationCompleted    = "completed"
+	ReplicationFailed       = "failed"
+)
+
+// SchedulerQuery describes filtering and pagination of scheduler requests
+type SchedulerQuery struct {
+	// Database limits docs to the given replicator database (docs only)
+	Database string
+	// States limits docs to the given states (docs only, CouchDB 3.x+)
+	States []string
+	Limit  int
+	Skip   int
+}
+
+// SchedulerInfo contains replication progress, when replication failed
+// Error holds the reason
+type SchedulerInfo struct {
+	RevisionsChecked      int      `json:"revisions_checked"`
+	MissingRevisionsFound int      `json:"missing_revisions_found"`
+	DocsRead              int      `json:"docs_read"`
+	DocsWritten           int      `json:"docs_written"`
+	DocWriteFailures      int      `json:"doc_write_failures"`
+	ChangesPending        int      `json:"changes_pending"`
+	CheckpointedSourceSeq Sequence `json:"checkpointed_source_seq"`
+	SourceSeq             Sequence `json:"source_seq"`
+	ThroughSeq            Sequence `json:"through_seq"`
+	Error                 string   `json:"error"`
+}
+
+// SchedulerEvent is a single record of replication job history
+type SchedulerEvent struct {
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// SchedulerJob is a replication job currently known to the scheduler
+type SchedulerJob struct {
+	ID        string           `json:"id"`
+	Database  string           `json:"database"`
+	DocID     string           `json:"doc_id"`
+	Node      string           `json:"node"`
+	Pid       string           `json:"pid"`
+	Source    string           `json:"source"`
+	Target    string           `json:"target"`
+	User      string           `json:"user"`
+	StartTime string           `json:"start_time"`
+	History   []SchedulerEvent `json:"history"`
+	Info      *SchedulerInfo   `json:"info"`
+}
+
+// SchedulerDoc is a state of replication defined in replicator database
+type SchedulerDoc struct {
+	ID          string         `json:"id"`
+	Database    string         `json:"database"`
+	DocID       string         `json:"doc_id"`
+	Node        string         `json:"node"`
+	Source      string         `json:"source"`
+	Target      string         `json:"target"`
+	State       string         `json:"state"`
+	ErrorCount  int            `json:"error_count"`
+	StartTime   string         `json:"start_time"`
+	LastUpdated string         `json:"last_updated"`
+	Info        *SchedulerInfo `json:"info"`
+}
+
+// SchedulerJobs is a page of scheduler jobs
+type SchedulerJobs struct {
+	Jobs      []SchedulerJob `json:"jobs"`
+	Offset    int            `json:"offset"`
+	TotalRows int            `json:"total_rows"`
+}
+
+// SchedulerDocs is a page of replication documents states
+type SchedulerDocs struct {
+	Docs      []SchedulerDoc `json:"docs"`
+	Offset    int            `json:"offset"`
+	TotalRows int            `json:"total_rows"`
+}
+
+// UnmarshalJSON accepts both info objects and plain error strings used by
+// some server versions for failed replications
+func (i *SchedulerInfo) UnmarshalJSON(data []byte) error {
+	*i = SchedulerInfo{}
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &i.Error)
+	}
+	type info SchedulerInfo
+	return json.Unmarshal(data, (*info)(i))
+}
+
+func (q *SchedulerQuery) options() Options {
+	options := Options{}
+	if q == nil {
+		return options
+	}
+	if q.Limit > 0 {
+		options["limit"] = q.Limit
+	}
+	if q.Skip > 0 {
+		options["skip"] = q.Skip
+	}
+	if len(q.States) > 0 {
+		options["states"] = strings.Join(q.States, ",")
+	}
+	return options
+}
+
+// SchedulerJobs returns replication jobs running or waiting to be run
+func (srv *Server) SchedulerJobs(q *SchedulerQuery) (*SchedulerJobs, error) {
+	options := q.options()
+	delete(options, "states")
+	resp, err := srv.conn.request("GET", "/_scheduler/jobs"+encodeOptions(options), nil, nil, srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out SchedulerJobs
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SchedulerDocs returns states of replications defined in replicator databases
+func (srv *Server) SchedulerDocs(q *SchedulerQuery) (*SchedulerDocs, error) {
+	URL := "/_scheduler/docs"
+	if q != nil && q.Database != "" {
+		URL += queryURL(q.Database)
+	}
+	resp, err := srv.conn.request("GET", URL+encodeOptions(q.options()), nil, nil, srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out SchedulerDocs
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SchedulerDoc returns state of a single replication document
+func (srv *Server) SchedulerDoc(database, id string) (*SchedulerDoc, error) {
+	resp, err := srv.conn.request("GET", queryURL("_scheduler", "docs", database, id), nil, nil, srv.auth, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out SchedulerDoc
+	if err := parseBody(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaitForReplication polls the scheduler until replication document with
+// given id from `_replicator` database is completed or failed, and returns
+// its final state. Failed replication is reported as an error along with
+// the state
+func (srv *Server) WaitForReplication(ctx context.Context, id string, interval time.Duration) (*SchedulerDoc, error) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		doc, err := srv.SchedulerDoc(replicatorDB, id)
+		if err != nil && !isStatus(err, 404) {
+			return nil, err
+		}
+		if doc != nil {
+			switch doc.State {
+			case ReplicationCompleted:
+				return doc, nil
+			case ReplicationFailed:
+				reason := ""
+				if doc.Info != nil {
+					reason = doc.Info.Error
+				}
+				return doc, fmt.Errorf("Replication %s failed: %s", id, reason)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return doc, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
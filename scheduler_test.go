@@ -0,0 +1,84 @@
+package gocouch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSchedulerInfo_UnmarshalJSON(t *testing.T) {
+	var doc SchedulerDoc
+	if err := json.Unmarshal([]byte(`{"state": "failed", "info": "db_not_found"}`), &doc); err != nil ||
+		doc.Info == nil || doc.Info.Error != "db_not_found" {
+		t.Logf("Error: %v, doc: %#v\n", err, doc)
+		t.Fail()
+		return
+	}
+	if err := json.Unmarshal([]byte(`{"state": "running", "info": {"docs_written": 3}}`), &doc); err != nil ||
+		doc.Info.DocsWritten != 3 || doc.Info.Error != "" {
+		t.Logf("Error: %v, doc: %#v\n", err, doc)
+		t.Fail()
+	}
+}
+
+func TestServer_SchedulerJobs(t *testing.T) {
+	srv := getConnection(t)
+	if _, err := srv.SchedulerJobs(&SchedulerQuery{Limit: 10}); err != nil {
+		// scheduler is only supported by couchdb 2.1+
+		if !isStatus(err, 400) && !isStatus(err, 404) {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+			return
+		}
+	}
+	if _, err := srv.SchedulerDocs(&SchedulerQuery{Database: replicatorDB, Limit: 10}); err != nil {
+		if !isStatus(err, 400) && !isStatus(err, 404) {
+			t.Logf("Error: %v\n", err)
+			t.Fail()
+		}
+	}
+}
+
+func TestServer_WaitForReplication(t *testing.T) {
+	srv := getConnection(t)
+	if _, err := srv.SchedulerDocs(nil); err != nil {
+		t.Skip("Scheduler is not supported by server")
+	}
+	source, err := srv.MustGetDatabase("scheduler_source", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer source.Delete()
+	source.Put("doc", map[string]string{"field": "value"})
+	from, _ := NewReplicationEndpoint("http://localhost:5984/scheduler_source", srv.auth)
+	to, _ := NewReplicationEndpoint("http://localhost:5984/scheduler_target", srv.auth)
+	doc := ReplicationDoc{
+		ID: "scheduler_replication",
+		ReplicationRequest: ReplicationRequest{
+			Source:       from,
+			Target:       to,
+			CreateTarget: true,
+		},
+	}
+	if err := srv.CreateReplication(&doc); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	state, err := srv.WaitForReplication(ctx, doc.ID, 500*time.Millisecond)
+	if err != nil || state.State != ReplicationCompleted {
+		t.Logf("Error: %v, state: %#v\n", err, state)
+		t.Fail()
+	}
+	if found, err := srv.GetReplication(doc.ID); err == nil {
+		srv.DeleteReplication(found.ID, found.Rev)
+	}
+	if target, err := srv.GetDatabase("scheduler_target", BasicAuth{"admin", "admin"}); err == nil {
+		target.Delete()
+	}
+}
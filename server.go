@@ -1,11 +1,12 @@
 package gocouch
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
 	"time"
@@ -27,9 +28,10 @@ type ServerInfo struct {
 
 // ServerEvent represents couchdb instance information about databases
 type ServerEvent struct {
-	Name string `json:"db_name"`
-	Ok   bool   `json:"ok"`
-	Type string `json:"type"`
+	Name string   `json:"db_name"`
+	Ok   bool     `json:"ok"`
+	Type string   `json:"type"`
+	Seq  Sequence `json:"seq,omitempty"`
 }
 
 // ReplicationResult provides information about replication request
@@ -38,7 +40,8 @@ type ReplicationResult struct {
 	Ok             bool                     `json:"ok"`
 	ReplicationVer int                      `json:"replication_id_version"`
 	SessionID      string                   `json:"session_id"`
-	SourceLastSeq  int                      `json:"source_last_seq"`
+	SourceLastSeq  Sequence                 `json:"source_last_seq"`
+	LocalID        string                   `json:"_local_id"`
 }
 
 // Connect tries to connect to the couchdb server using given host, port and optionally
@@ -105,84 +108,48 @@ func (srv *Server) GetAllDBs() (dbList []string, err error) {
 // 	http://docs.couchdb.org/en/1.6.1/api/server/common.html#db-updates
 // Note: `timeout` option accepts milliseconds instead of seconds
 func (srv *Server) GetDBEvent(o interface{}, options Options) error {
-	url := ""
-	for k, v := range options {
-		url += fmt.Sprintf("&%s=%v", k, v)
-	}
-	if len(url) > 0 {
-		url = strings.Trim(url, "&")
-		url = "/_db_updates?" + url
-	} else {
-		url = "/_db_updates"
-	}
-	resp, err := srv.conn.request("GET", url, nil, nil, srv.auth, 0)
+	resp, err := srv.conn.request("GET", "/_db_updates"+encodeOptions(options), nil, nil, srv.auth, 0)
 	if err != nil {
 		return err
 	}
-	if err := parseBody(resp, o); err != nil {
-		if strings.Contains(err.Error(), "EOF") {
-			return nil
-		}
+	if err := parseBody(resp, o); err != nil && err != io.EOF {
+		// empty body means that timeout exceeded without any events
+		return err
 	}
-	return err
+	return nil
 }
 
 // GetDBEventChan returns channel that provides events happened on couchdb instance,
-// it's thread safe to use in other goroutines. Also, you must close channel after all things
-// done to release resourses and prevent memory leaks.
+// it's thread safe to use in other goroutines. Channel is closed when the feed
+// ends, e.g. on network error. It can't be cancelled, so don't close it and keep
+// reading until it's closed; use DBUpdates to be able to stop the feed.
+//
+// Deprecated: use DBUpdates which can be closed safely and reports feed errors
 func (srv *Server) GetDBEventChan() (c chan ServerEvent, err error) {
-	c = make(chan ServerEvent)
-	cpSrv, err := srv.Copy()
-	if err != nil {
-		return nil, err
-	}
-	resp, err := cpSrv.conn.request("GET", "/_db_updates?feed=continuous", nil, nil, srv.auth, 0)
+	feed, err := srv.DBUpdates(context.Background(), DBUpdatesOptions{Feed: FeedContinuous})
 	if err != nil {
 		return nil, err
 	}
+	c = make(chan ServerEvent)
 	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				// channel closed externally, close the connection
-				resp.Body.Close()
-				if err != nil {
-					c = nil
-					switch r.(type) {
-					case error:
-						err = r.(error)
-					}
-				}
-			}
-		}()
-		reader := bufio.NewReader(resp.Body)
-		for {
-			line, intErr := reader.ReadBytes('\n')
-			if intErr != nil {
-				panic("Failed to read bytes from connection")
-			}
-			var payload ServerEvent
-			intErr = json.Unmarshal(line, &payload)
-			if intErr != nil {
-				panic("Failed to unmarshal bytes to ServerEvent")
-			}
-			c <- payload
+		defer close(c)
+		defer feed.Close()
+		for event := range feed.Events() {
+			c <- event
 		}
 	}()
-	return c, err
+	return c, nil
 }
 
-// GetMembership returns lists of cluster and all nodes
+// GetMembership returns lists of cluster and all nodes, see Membership
+// for typed result
 func (srv *Server) GetMembership(o interface{}) error {
 	resp, err := srv.conn.request("GET", "/_membership", nil, nil, srv.auth, 0)
 	if err != nil {
-		switch err.(type) {
-		case *Error:
-			if err.(*Error).StatusCode == 400 {
-				return errors.New("Not supported by server")
-			}
-		default:
-			return err
+		if isStatus(err, 400) {
+			return errors.New("Not supported by server")
 		}
+		return err
 	}
 	return parseBody(resp, &o)
 }
@@ -208,17 +175,12 @@ func (srv *Server) GetLog(size int) (*bytes.Buffer, error) {
 	return bytes.NewBuffer(payload), nil
 }
 
-// Replicate provides replication management
-func (srv *Server) Replicate(source, target string, options Options) (*ReplicationResult, error) {
-	request := make(map[string]interface{})
-	headers := make(map[string]string)
-	headers["Content-Type"] = "application/json"
-	request["source"] = source
-	request["target"] = target
-	for k, v := range options {
-		request[k] = v
-	}
-	payload, err := json.Marshal(request)
+// Replicate starts transient replication described by request. One-off
+// replication blocks until it's finished, continuous one returns immediately
+// and may be stopped with CancelReplication
+func (srv *Server) Replicate(req *ReplicationRequest) (*ReplicationResult, error) {
+	headers := map[string]string{"Content-Type": appJSON}
+	payload, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
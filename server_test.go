@@ -2,7 +2,11 @@ package gocouch
 
 import (
 	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -80,6 +84,40 @@ func TestServer_GetDBEvent(t *testing.T) {
 	}
 }
 
+func TestServer_GetDBEventChanFeedError(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"error":"unknown_error","reason":"down"}`))
+			return
+		}
+		w.Write([]byte(`{"db_name":"db","type":"created","seq":"1-a"}` + "\n"))
+	}))
+	defer ts.Close()
+	conn, _ := createConnection(ts.URL, 0)
+	srv := &Server{conn: conn}
+	events, err := srv.GetDBEventChan()
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Log("Channel is not closed after feed error")
+			t.Fail()
+			return
+		}
+	}
+}
+
 func TestServer_GetDBEventChan(t *testing.T) {
 	srv := getConnection(t)
 	events, err := srv.GetDBEventChan()
@@ -88,9 +126,6 @@ func TestServer_GetDBEventChan(t *testing.T) {
 		t.Fail()
 		return
 	}
-	defer func() {
-		close(events)
-	}()
 	db, err := srv.MustGetDatabase("db_events_2", BasicAuth{"admin", "admin"})
 	if err != nil {
 		t.Logf("Error: %v\n", err)
@@ -112,6 +147,42 @@ func TestServer_GetDBEventChan(t *testing.T) {
 	}
 }
 
+func TestServer_DBUpdates(t *testing.T) {
+	srv := getConnection(t)
+	feed, err := srv.DBUpdates(context.Background(), DBUpdatesOptions{Heartbeat: time.Second})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	db, err := srv.MustGetDatabase("db_updates", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	select {
+	case event, ok := <-feed.Events():
+		if !ok || event.Name != "db_updates" {
+			t.Logf("Unexpected event: %#v, error: %v\n", event, feed.Err())
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("Timeout waiting for event")
+		t.Fail()
+	}
+	if err := feed.Close(); err != nil || feed.Err() != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if _, ok := <-feed.Events(); ok {
+		t.Log("Events channel is not closed")
+		t.Fail()
+	}
+}
+
 func TestServer_GetMembership(t *testing.T) {
 	srv := getConnection(t)
 	var result map[string][]string
@@ -144,7 +215,13 @@ func TestServer_GetLog(t *testing.T) {
 func TestServer_Replicate(t *testing.T) {
 	srv := getConnection(t)
 	srv.MustGetDatabase("testing", BasicAuth{"admin", "admin"})
-	result, err := srv.Replicate("testing", "testing2", Options{"create_target": true})
+	source, _ := NewReplicationEndpoint("http://localhost:5984/testing", srv.auth)
+	target, _ := NewReplicationEndpoint("http://localhost:5984/testing2", srv.auth)
+	result, err := srv.Replicate(&ReplicationRequest{
+		Source:       source,
+		Target:       target,
+		CreateTarget: true,
+	})
 	if err != nil {
 		t.Logf("Error: %v\n", err)
 		t.Fail()
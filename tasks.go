@@ -0,0 +1,313 @@
+package gocouch
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of active tasks
+const (
+	TaskDatabaseCompaction = "database_compaction"
+	TaskViewCompaction     = "view_compaction"
+	TaskIndexer            = "indexer"
+	TaskReplication        = "replication"
+	TaskSearchIndexer      = "search_indexer"
+)
+
+// Task is implemented by all typed active tasks
+type Task interface {
+	// Info returns fields common for all tasks
+	Info() *TaskInfo
+}
+
+// TaskInfo contains fields common for all active tasks, it's also returned
+// for tasks of unknown types
+type TaskInfo struct {
+	Type         string `json:"type"`
+	Node         string `json:"node"`
+	Pid          string `json:"pid"`
+	Database     string `json:"database"`
+	Progress     int    `json:"progress"`
+	ChangesDone  int    `json:"changes_done"`
+	TotalChanges int    `json:"total_changes"`
+	StartedOn    int64  `json:"started_on"`
+	UpdatedOn    int64  `json:"updated_on"`
+}
+
+// DatabaseCompactionTask describes running database compaction
+type DatabaseCompactionTask struct {
+	TaskInfo
+}
+
+// ViewCompactionTask describes running compaction of design document indexes
+type ViewCompactionTask struct {
+	TaskInfo
+	DesignDocument string `json:"design_document"`
+	Phase          string `json:"phase"`
+}
+
+// IndexerTask describes view index building
+type IndexerTask struct {
+	TaskInfo
+	DesignDocument string `json:"design_document"`
+}
+
+// SearchIndexerTask describes search index building
+type SearchIndexerTask struct {
+	TaskInfo
+	DesignDocument string `json:"design_document"`
+	Index          string `json:"index"`
+}
+
+// ReplicationTask describes running replication
+type ReplicationTask struct {
+	TaskInfo
+	ReplicationID         string   `json:"replication_id"`
+	DocID                 string   `json:"doc_id"`
+	Source                string   `json:"source"`
+	Target                string   `json:"target"`
+	Continuous            bool     `json:"continuous"`
+	DocsRead              int      `json:"docs_read"`
+	DocsWritten           int      `json:"docs_written"`
+	DocWriteFailures      int      `json:"doc_write_failures"`
+	MissingRevisionsFound int      `json:"missing_revisions_found"`
+	RevisionsChecked      int      `json:"revisions_checked"`
+	CheckpointedSourceSeq Sequence `json:"checkpointed_source_seq"`
+	SourceSeq             Sequence `json:"source_seq"`
+	ThroughSeq            Sequence `json:"through_seq"`
+}
+
+// TaskFilter selects tasks to watch, empty fields match any value
+type TaskFilter struct {
+	Type           string
+	Database       string
+	DesignDocument string
+}
+
+// TaskProgress is an update emitted by TaskWatcher, Done is set when task
+// disappeared from active tasks, in this case Task holds its last known state
+type TaskProgress struct {
+	Task Task
+	Done bool
+}
+
+// TaskWatcher polls active tasks and reports progress of matching ones
+type TaskWatcher struct {
+	srv      *Server
+	filter   TaskFilter
+	interval time.Duration
+	updates  chan TaskProgress
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+	running  int
+	err      error
+}
+
+// Info returns fields common for all tasks
+func (t *TaskInfo) Info() *TaskInfo {
+	return t
+}
+
+// ActiveTasks returns typed tasks running on the server
+func (srv *Server) ActiveTasks() ([]Task, error) {
+	var raw []json.RawMessage
+	if err := srv.GetActiveTasks(&raw); err != nil {
+		return nil, err
+	}
+	tasks := make([]Task, 0, len(raw))
+	for _, data := range raw {
+		var info TaskInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, err
+		}
+		var task Task
+		switch info.Type {
+		case TaskDatabaseCompaction:
+			task = &DatabaseCompactionTask{}
+		case TaskViewCompaction:
+			task = &ViewCompactionTask{}
+		case TaskIndexer:
+			task = &IndexerTask{}
+		case TaskSearchIndexer:
+			task = &SearchIndexerTask{}
+		case TaskReplication:
+			task = &ReplicationTask{}
+		default:
+			task = &info
+		}
+		if err := json.Unmarshal(data, task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// Match reports whether task is selected by filter
+func (f TaskFilter) Match(task Task) bool {
+	info := task.Info()
+	if f.Type != "" && info.Type != f.Type {
+		return false
+	}
+	if f.Database != "" && !matchDatabase(info.Database, f.Database) {
+		return false
+	}
+	if f.DesignDocument != "" {
+		ddoc := ""
+		switch t := task.(type) {
+		case *ViewCompactionTask:
+			ddoc = t.DesignDocument
+		case *IndexerTask:
+			ddoc = t.DesignDocument
+		case *SearchIndexerTask:
+			ddoc = t.DesignDocument
+		}
+		if strings.TrimPrefix(ddoc, "_design/") != strings.TrimPrefix(f.DesignDocument, "_design/") {
+			return false
+		}
+	}
+	return true
+}
+
+// matchDatabase compares database of a task with given name, in a cluster
+// tasks refer to shards like "shards/00000000-1fffffff/name.1512345678"
+func matchDatabase(taskDB, name string) bool {
+	if taskDB == name {
+		return true
+	}
+	if !strings.HasPrefix(taskDB, "shards/") {
+		return false
+	}
+	shard := taskDB[strings.LastIndex(taskDB, "/")+1:]
+	return strings.HasPrefix(shard, name+".")
+}
+
+// WatchTasks starts polling active tasks matching filter with given interval
+func (srv *Server) WatchTasks(ctx context.Context, filter TaskFilter, interval time.Duration) *TaskWatcher {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w := &TaskWatcher{
+		srv:      srv,
+		filter:   filter,
+		interval: interval,
+		updates:  make(chan TaskProgress),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go w.run(ctx)
+	return w
+}
+
+// WaitForTasks blocks until no task matching filter is running
+func (srv *Server) WaitForTasks(ctx context.Context, filter TaskFilter, interval time.Duration) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		tasks, err := srv.ActiveTasks()
+		if err != nil {
+			return err
+		}
+		running := false
+		for _, task := range tasks {
+			if filter.Match(task) {
+				running = true
+				break
+			}
+		}
+		if !running {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Updates returns channel of task updates, it's closed when watcher stops
+func (w *TaskWatcher) Updates() <-chan TaskProgress {
+	return w.updates
+}
+
+// Running returns count of matching tasks seen during the last poll
+func (w *TaskWatcher) Running() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.running
+}
+
+// Err returns error that stopped the watcher
+func (w *TaskWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops the watcher
+func (w *TaskWatcher) Close() {
+	w.cancel()
+	<-w.done
+}
+
+// taskKey identifies task across polls, pids are unique only within a node
+func taskKey(task Task) string {
+	info := task.Info()
+	return info.Node + "/" + info.Type + "/" + info.Pid
+}
+
+func (w *TaskWatcher) run(ctx context.Context) {
+	defer close(w.done)
+	defer close(w.updates)
+	seen := make(map[string]Task)
+	for {
+		tasks, err := w.srv.ActiveTasks()
+		if err != nil {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+			return
+		}
+		var updates []TaskProgress
+		current := make(map[string]Task)
+		for _, task := range tasks {
+			if !w.filter.Match(task) {
+				continue
+			}
+			key := taskKey(task)
+			current[key] = task
+			if prev, ok := seen[key]; !ok || prev.Info().UpdatedOn != task.Info().UpdatedOn ||
+				prev.Info().Progress != task.Info().Progress {
+				updates = append(updates, TaskProgress{Task: task})
+			}
+		}
+		for key, task := range seen {
+			if _, ok := current[key]; !ok {
+				updates = append(updates, TaskProgress{Task: task, Done: true})
+			}
+		}
+		seen = current
+		w.mu.Lock()
+		w.running = len(current)
+		w.mu.Unlock()
+		for _, update := range updates {
+			select {
+			case w.updates <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.interval):
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gocouch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTaskFilter_Match(t *testing.T) {
+	task := &ViewCompactionTask{
+		TaskInfo:       TaskInfo{Type: TaskViewCompaction, Database: "shards/00000000-1fffffff/orders.1512345678"},
+		DesignDocument: "_design/reports",
+	}
+	if !(TaskFilter{Type: TaskViewCompaction, Database: "orders", DesignDocument: "reports"}).Match(task) {
+		t.Log("Task of sharded database is not matched")
+		t.Fail()
+	}
+	if (TaskFilter{Database: "order"}).Match(task) {
+		t.Log("Task of other database is matched")
+		t.Fail()
+	}
+	if (TaskFilter{Type: TaskIndexer}).Match(task) {
+		t.Log("Task of other type is matched")
+		t.Fail()
+	}
+}
+
+func TestTaskKey(t *testing.T) {
+	first := &IndexerTask{TaskInfo: TaskInfo{Type: TaskIndexer, Node: "couchdb@node1", Pid: "<0.123.0>"}}
+	second := &IndexerTask{TaskInfo: TaskInfo{Type: TaskIndexer, Node: "couchdb@node2", Pid: "<0.123.0>"}}
+	if taskKey(first) == taskKey(second) {
+		t.Log("Tasks of different nodes share the key")
+		t.Fail()
+	}
+}
+
+func TestServer_ActiveTasks(t *testing.T) {
+	srv := getConnection(t)
+	if _, err := srv.ActiveTasks(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestServer_WatchTasks(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("watch_tasks", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watcher := srv.WatchTasks(ctx, TaskFilter{Database: "watch_tasks"}, 100*time.Millisecond)
+	defer watcher.Close()
+	if err := db.Compact(); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if err := srv.WaitForTasks(ctx, TaskFilter{Database: "watch_tasks"}, 100*time.Millisecond); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	watcher.Close()
+	if watcher.Err() != nil {
+		t.Logf("Error: %v\n", watcher.Err())
+		t.Fail()
+	}
+}
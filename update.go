@@ -0,0 +1,106 @@
+package gocouch
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrTooManyConflicts is returned when document update keeps conflicting
+// with concurrent writers after all retries
+var ErrTooManyConflicts = errors.New("Too many conflicts updating document")
+
+// UpdateOptions controls read-modify-write updates, zero values mean defaults
+type UpdateOptions struct {
+	// MaxRetries limits retries on conflict, 5 by default
+	MaxRetries int
+	// Backoff is a delay before the first retry, it's doubled for every
+	// next one and randomized, 20ms by default
+	Backoff time.Duration
+	// Upsert creates document when it does not exist
+	Upsert bool
+}
+
+func (o *UpdateOptions) withDefaults() UpdateOptions {
+	var out UpdateOptions
+	if o != nil {
+		out = *o
+	}
+	if out.MaxRetries <= 0 {
+		out.MaxRetries = 5
+	}
+	if out.Backoff <= 0 {
+		out.Backoff = 20 * time.Millisecond
+	}
+	return out
+}
+
+// UpdateFunc fetches the latest revision of the document, applies fn to it
+// and saves the result. On conflict the document is fetched again and fn is
+// reapplied, so fn must not have side effects. When document is missing
+// and Upsert is set fn gets an empty document with exists set to false.
+// Final document and its revision are returned
+func (db *Database) UpdateFunc(id string, fn func(doc map[string]interface{}, exists bool) error,
+	options *UpdateOptions) (map[string]interface{}, string, error) {
+
+	doc, rev, err := db.updateLoop(id, func() interface{} {
+		return &map[string]interface{}{}
+	}, func(doc interface{}, exists bool) error {
+		return fn(*doc.(*map[string]interface{}), exists)
+	}, options)
+	if err != nil {
+		return nil, "", err
+	}
+	return *doc.(*map[string]interface{}), rev, nil
+}
+
+// updateLoop runs read-modify-write cycle decoding document into a value
+// returned by newDoc, saved document gets new `_id` and `_rev`
+func (db *Database) updateLoop(id string, newDoc func() interface{}, fn func(doc interface{}, exists bool) error,
+	options *UpdateOptions) (interface{}, string, error) {
+
+	opts := options.withDefaults()
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		doc := newDoc()
+		err := db.Get(docPath(id), doc, nil)
+		exists := err == nil
+		if err != nil && !(opts.Upsert && isStatus(err, 404)) {
+			return nil, "", err
+		}
+		if !exists {
+			if m, ok := doc.(*map[string]interface{}); ok {
+				(*m)["_id"] = id
+			} else {
+				setDocumentMeta(doc, id, "")
+			}
+		}
+		if err := fn(doc, exists); err != nil {
+			return nil, "", err
+		}
+		rev, err := db.Put(docPath(id), doc)
+		if err == nil {
+			if m, ok := doc.(*map[string]interface{}); ok {
+				setDocumentMeta(*m, id, rev)
+			} else {
+				setDocumentMeta(doc, id, rev)
+			}
+			return doc, rev, nil
+		}
+		// document created concurrently is a conflict as well
+		if !isStatus(err, 409) {
+			return nil, "", err
+		}
+		if attempt >= opts.MaxRetries {
+			return nil, "", ErrTooManyConflicts
+		}
+		sleepBackoff(&backoff)
+	}
+}
+
+// sleepBackoff waits for randomized backoff, so concurrent writers don't
+// retry in lockstep, and doubles it for the next retry
+func sleepBackoff(backoff *time.Duration) {
+	time.Sleep(*backoff/2 + time.Duration(rand.Int63n(int64(*backoff))))
+	*backoff *= 2
+}
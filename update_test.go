@@ -0,0 +1,51 @@
+package gocouch
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestDatabase_UpdateFunc(t *testing.T) {
+	srv := getConnection(t)
+	db, err := srv.MustGetDatabase("update_func_test", BasicAuth{"admin", "admin"})
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer db.Delete()
+	increment := func(doc map[string]interface{}, exists bool) error {
+		if !exists {
+			doc["count"] = 0.0
+		}
+		doc["count"] = doc["count"].(float64) + 1
+		return nil
+	}
+	if _, _, err := db.UpdateFunc("counter", increment, nil); !isStatus(err, 404) {
+		t.Logf("Expected 404 without upsert, got: %v\n", err)
+		t.Fail()
+	}
+	options := &UpdateOptions{Upsert: true, MaxRetries: 50}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := db.UpdateFunc("counter", increment, options); err != nil {
+				t.Logf("Error: %v\n", err)
+				t.Fail()
+			}
+		}()
+	}
+	wg.Wait()
+	doc, rev, err := db.UpdateFunc("counter", increment, options)
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if doc["count"].(float64) != 6 || doc["_rev"] != rev || rev[:2] != "6-" {
+		t.Logf("Unexpected document: %v, rev: %v\n", doc, rev)
+		t.Fail()
+	}
+}
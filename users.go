@@ -0,0 +1,274 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+)
+
+const (
+	usersDB    = "_users"
+	userPrefix = "org.couchdb.user:"
+	// UserType is a type of regular user documents
+	UserType = "user"
+)
+
+// Kinds of UserError returned by users management methods, match them with
+// errors.Is
+var (
+	ErrUserNotFound  = errors.New("User not found")
+	ErrUserExists    = errors.New("User already exists")
+	ErrUserConflict  = errors.New("User was modified concurrently")
+	ErrUserForbidden = errors.New("Not allowed to manage user")
+)
+
+// UserRecord is a document of `_users` database. Password is set only to
+// change it, server stores its hash in the derived fields instead
+type UserRecord struct {
+	ID             string   `json:"_id,omitempty"`
+	Rev            string   `json:"_rev,omitempty"`
+	Login          string   `json:"name"`
+	Type           string   `json:"type"`
+	Roles          []string `json:"roles"`
+	Password       string   `json:"password,omitempty"`
+	PasswordScheme string   `json:"password_scheme,omitempty"`
+	Iterations     int      `json:"iterations,omitempty"`
+	DerivedKey     string   `json:"derived_key,omitempty"`
+	Salt           string   `json:"salt,omitempty"`
+	PasswordSHA    string   `json:"password_sha,omitempty"`
+	// Fields holds custom fields of user document
+	Fields map[string]interface{} `json:"-"`
+}
+
+// userRecord prevents recursion in (un)marshalling
+type userRecord UserRecord
+
+var userRecordFields = []string{"_id", "_rev", "name", "type", "roles", "password",
+	"password_scheme", "iterations", "derived_key", "salt", "password_sha"}
+
+// MarshalJSON adds custom fields to the document
+func (u UserRecord) MarshalJSON() ([]byte, error) {
+	if u.Roles == nil {
+		u.Roles = []string{}
+	}
+	data, err := json.Marshal(userRecord(u))
+	if err != nil || len(u.Fields) == 0 {
+		return data, err
+	}
+	doc := make(map[string]interface{}, len(u.Fields))
+	for k, v := range u.Fields {
+		doc[k] = v
+	}
+	var known map[string]interface{}
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+	for k, v := range known {
+		doc[k] = v
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON collects unknown fields of the document into Fields
+func (u *UserRecord) UnmarshalJSON(data []byte) error {
+	var record userRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, k := range userRecordFields {
+		delete(fields, k)
+	}
+	if len(fields) > 0 {
+		record.Fields = fields
+	}
+	*u = UserRecord(record)
+	return nil
+}
+
+func userID(name string) string {
+	return userPrefix + name
+}
+
+// userPath returns escaped user document id to be used in urls
+func userPath(name string) string {
+	return url.PathEscape(userID(name))
+}
+
+// users returns `_users` database without checking its existence
+func (srv *Server) users() *Database {
+	return &Database{conn: srv.conn, auth: srv.auth, Name: usersDB}
+}
+
+// UserError is returned by users management methods, it matches one of
+// ErrUser* values with errors.Is and keeps the server error, whose Reason
+// holds e.g. a message of `_users` validation function
+type UserError struct {
+	Kind error
+	Err  *Error
+}
+
+func (e *UserError) Error() string {
+	if e.Err.Reason == "" {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Reason
+}
+
+// Is reports whether target is the kind of the error
+func (e *UserError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the server error
+func (e *UserError) Unwrap() error {
+	return e.Err
+}
+
+// userError converts server error into typed one, conflict depends on
+// whether user was being created or updated
+func userError(err, conflict error) error {
+	e, ok := err.(*Error)
+	if !ok {
+		return err
+	}
+	switch e.StatusCode {
+	case 404:
+		return &UserError{Kind: ErrUserNotFound, Err: e}
+	case 409:
+		return &UserError{Kind: conflict, Err: e}
+	case 401, 403:
+		return &UserError{Kind: ErrUserForbidden, Err: e}
+	}
+	return err
+}
+
+// CreateUser inserts user record to couchdb _users database, Type defaults
+// to "user". ID and Rev of the record are updated on success
+func (srv *Server) CreateUser(user *UserRecord) error {
+	if user.Type == "" {
+		user.Type = UserType
+	}
+	record := *user
+	record.ID, record.Rev = userID(user.Login), ""
+	rev, err := srv.users().Put(userPath(user.Login), &record)
+	if err != nil {
+		return userError(err, ErrUserExists)
+	}
+	user.ID, user.Rev = record.ID, rev
+	return nil
+}
+
+// GetUser returns user record by user name
+func (srv *Server) GetUser(name string) (*UserRecord, error) {
+	var user UserRecord
+	if err := srv.users().Get(userPath(name), &user, nil); err != nil {
+		return nil, userError(err, ErrUserConflict)
+	}
+	return &user, nil
+}
+
+// UpdateUser saves user record, when Rev is empty the latest one is used,
+// otherwise ErrUserConflict is returned if record was changed meanwhile
+func (srv *Server) UpdateUser(user *UserRecord) error {
+	if user.Type == "" {
+		user.Type = UserType
+	}
+	record := *user
+	record.ID = userID(user.Login)
+	if record.Rev == "" {
+		current, err := srv.GetUser(user.Login)
+		if err != nil {
+			return err
+		}
+		record.Rev = current.Rev
+	}
+	rev, err := srv.users().Put(userPath(user.Login), &record)
+	if err != nil {
+		return userError(err, ErrUserConflict)
+	}
+	user.ID, user.Rev, user.Password = record.ID, rev, ""
+	return nil
+}
+
+// modifyUser applies change to the latest user record and saves it
+func (srv *Server) modifyUser(name string, change func(*UserRecord)) error {
+	user, err := srv.GetUser(name)
+	if err != nil {
+		return err
+	}
+	change(user)
+	return srv.UpdateUser(user)
+}
+
+// ChangePassword sets new password of the user
+func (srv *Server) ChangePassword(name, password string) error {
+	return srv.modifyUser(name, func(user *UserRecord) {
+		user.Password = password
+	})
+}
+
+// SetRoles replaces roles of the user
+func (srv *Server) SetRoles(name string, roles []string) error {
+	return srv.modifyUser(name, func(user *UserRecord) {
+		user.Roles = roles
+	})
+}
+
+// DeleteUser removes user record by user name
+func (srv *Server) DeleteUser(name string) error {
+	user, err := srv.GetUser(name)
+	if err != nil {
+		return err
+	}
+	if _, err := srv.users().Del(userPath(name), user.Rev); err != nil {
+		return userError(err, ErrUserConflict)
+	}
+	return nil
+}
+
+// ListUsers returns up to limit users sorted by name, which follow the user
+// named after. Pass name of the last returned user to get the next page
+func (srv *Server) ListUsers(after string, limit int) ([]UserRecord, error) {
+	startKey, err := json.Marshal(userID(after))
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := json.Marshal(userPrefix + "\ufff0")
+	if err != nil {
+		return nil, err
+	}
+	options := Options{"include_docs": true, "startkey": string(startKey), "endkey": string(endKey)}
+	if limit > 0 {
+		// the user named after is included when it still exists
+		options["limit"] = limit + 1
+	}
+	db := srv.users()
+	resp, err := db.conn.request("GET", queryURL(db.Name, "_all_docs")+encodeOptions(options), nil, nil, db.auth, 0)
+	if err != nil {
+		return nil, userError(err, ErrUserConflict)
+	}
+	var result struct {
+		Rows []struct {
+			ID  string      `json:"id"`
+			Doc *UserRecord `json:"doc"`
+		} `json:"rows"`
+	}
+	if err := parseBody(resp, &result); err != nil {
+		return nil, err
+	}
+	users := make([]UserRecord, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		if row.Doc == nil || (after != "" && row.ID == userID(after)) {
+			continue
+		}
+		users = append(users, *row.Doc)
+	}
+	if limit > 0 && len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
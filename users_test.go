@@ -0,0 +1,111 @@
+package gocouch
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUserError(t *testing.T) {
+	reason := "doc.roles must be an array of strings"
+	err := userError(&Error{StatusCode: 403, ErrorCode: "forbidden", Reason: reason}, ErrUserConflict)
+	if !errors.Is(err, ErrUserForbidden) || errors.Is(err, ErrUserConflict) || !strings.Contains(err.Error(), reason) {
+		t.Logf("Unexpected error: %v\n", err)
+		t.Fail()
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Reason != reason {
+		t.Logf("Server error is lost: %v\n", err)
+		t.Fail()
+	}
+}
+
+func TestUserRecord_MarshalJSON(t *testing.T) {
+	user := UserRecord{Login: "milk", Type: UserType, Fields: map[string]interface{}{"email": "milk@example.com"}}
+	data, err := json.Marshal(user)
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	var decoded UserRecord
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if decoded.Login != "milk" || decoded.Fields["email"] != "milk@example.com" || len(decoded.Fields) != 1 {
+		t.Logf("Unexpected record: %+v\n", decoded)
+		t.Fail()
+	}
+	if decoded.Roles == nil {
+		t.Log("Roles must be encoded as an empty list")
+		t.Fail()
+	}
+}
+
+func TestServer_UserManagement(t *testing.T) {
+	srv := getConnection(t)
+	user := UserRecord{Login: "gocouch_user", Password: "secret"}
+	if err := srv.CreateUser(&user); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	defer srv.DeleteUser("gocouch_user")
+	if user.Type != UserType || user.Rev == "" {
+		t.Logf("Unexpected record: %+v\n", user)
+		t.Fail()
+	}
+	if err := srv.CreateUser(&UserRecord{Login: "gocouch_user"}); !errors.Is(err, ErrUserExists) {
+		t.Logf("Expected ErrUserExists, got: %v\n", err)
+		t.Fail()
+	}
+	if err := srv.SetRoles("gocouch_user", []string{"reader"}); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if err := srv.ChangePassword("gocouch_user", "new_secret"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	stored, err := srv.GetUser("gocouch_user")
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	if len(stored.Roles) != 1 || stored.Roles[0] != "reader" {
+		t.Logf("Unexpected roles: %v\n", stored.Roles)
+		t.Fail()
+	}
+	if err := srv.UpdateUser(&user); !errors.Is(err, ErrUserConflict) {
+		t.Logf("Expected ErrUserConflict, got: %v\n", err)
+		t.Fail()
+	}
+	users, err := srv.ListUsers("", 0)
+	if err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+		return
+	}
+	found := false
+	for _, u := range users {
+		found = found || u.Login == "gocouch_user"
+	}
+	if !found {
+		t.Log("User is not listed")
+		t.Fail()
+	}
+	if err := srv.DeleteUser("gocouch_user"); err != nil {
+		t.Logf("Error: %v\n", err)
+		t.Fail()
+	}
+	if _, err := srv.GetUser("gocouch_user"); !errors.Is(err, ErrUserNotFound) {
+		t.Logf("Expected ErrUserNotFound, got: %v\n", err)
+		t.Fail()
+	}
+}